@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ndv6/gate/internal/modules/audit"
+	"github.com/ndv6/gate/warden"
+)
+
+// AuditQuerier retrieves logged decisions, most recent first
+type AuditQuerier = audit.Querier
+
+// AuditQuerierFactory returns logged decisions of a merchant
+type AuditQuerierFactory func(merchant string) (AuditQuerier, error)
+
+// Decisions serves decisions logged for `merchant`, most recent first
+// optional query parameters `subject`, `resource`, `decision` (allow, deny or step_up) and `from`, `to`
+// unix timestamps filter them, `limit` (defaults to 100, at most 1000) and `offset` page through them
+func Decisions(queriers AuditQuerierFactory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+
+		q := r.URL.Query()
+		merchant := q.Get("merchant")
+		if merchant == "" {
+			writeError(w, http.StatusBadRequest, "merchant is required")
+			return
+		}
+		if !validMerchant(w, merchant) {
+			return
+		}
+
+		f := audit.Filter{Subject: q.Get("subject"), Resource: q.Get("resource"), Decision: q.Get("decision")}
+		switch f.Decision {
+		case "", warden.OutcomeAllow, warden.OutcomeDeny, warden.OutcomeStepUp:
+		default:
+			writeError(w, http.StatusBadRequest, "unknown decision "+f.Decision)
+			return
+		}
+		for name, v := range map[string]*int64{"from": &f.From, "to": &f.To, "limit": &f.Limit, "offset": &f.Offset} {
+			s := q.Get(name)
+			if s == "" {
+				continue
+			}
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, "invalid "+name)
+				return
+			}
+			*v = n
+		}
+		if f.Limit > audit.MaxQueryLimit {
+			writeError(w, http.StatusBadRequest, "limit exceeds "+strconv.Itoa(audit.MaxQueryLimit))
+			return
+		}
+
+		querier, err := queriers(merchant)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		entries, err := querier.Query(f)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, entries)
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Authenticator tells whether a request comes from a caller allowed to use the API
+type Authenticator func(r *http.Request) bool
+
+// BearerToken authenticates requests carrying `Authorization: Bearer <token>`, an empty token authenticates nothing
+func BearerToken(token string) Authenticator {
+	return func(r *http.Request) bool {
+		header := r.Header.Get("Authorization")
+		if token == "" || !strings.HasPrefix(header, "Bearer ") {
+			return false
+		}
+		given := strings.TrimPrefix(header, "Bearer ")
+		return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+	}
+}
+
+// authenticated refuses requests auth doesn't accept, every request is refused when auth is nil
+func authenticated(auth Authenticator, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth == nil || !auth(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/ndv6/gate/registry"
+)
+
+// ConditionCatalog serves every registered condition type along with its options JSON Schema
+// a single condition is served when the `name` query parameter is given
+func ConditionCatalog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+
+		catalog := registry.Catalog()
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			writeJSON(w, http.StatusOK, catalog)
+			return
+		}
+		for _, e := range catalog {
+			if e.Name == name {
+				writeJSON(w, http.StatusOK, e)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "condition type not registered")
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/ndv6/gate/warden"
+)
+
+// Grantees serves subjects allowed to perform `action` on `resource` at `merchant`, all given as query parameters
+// the list is exported as CSV when the `format` query parameter is `csv`
+func Grantees(wardens WardenFactory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+
+		q := r.URL.Query()
+		merchant, resource, action := q.Get("merchant"), q.Get("resource"), q.Get("action")
+		if merchant == "" || resource == "" || action == "" {
+			writeError(w, http.StatusBadRequest, "merchant, resource and action are required")
+			return
+		}
+		if !validMerchant(w, merchant) {
+			return
+		}
+
+		wd, err := wardens(merchant)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		grantees, err := wd.Grantees(resource, action)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if q.Get("format") != "csv" {
+			writeJSON(w, http.StatusOK, grantees)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="grantees.csv"`)
+		w.WriteHeader(http.StatusOK)
+		_ = warden.WriteGranteesCSV(w, grantees)
+	}
+}
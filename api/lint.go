@@ -0,0 +1,60 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/ndv6/gate/internal/modules/linter"
+)
+
+// LintPolicies serves findings of the policy linter
+// GET analyzes policies stored for the `merchant` query parameter, POST analyzes a JSON list of policies in the body
+func LintPolicies(wardens WardenFactory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			findings []linter.Finding
+			err      error
+		)
+
+		switch r.Method {
+		case http.MethodGet:
+			merchant := r.URL.Query().Get("merchant")
+			if merchant == "" {
+				writeError(w, http.StatusBadRequest, "merchant is required")
+				return
+			}
+			if !validMerchant(w, merchant) {
+				return
+			}
+			wd, werr := wardens(merchant)
+			if werr != nil {
+				writeError(w, http.StatusNotFound, werr.Error())
+				return
+			}
+			findings, err = linter.LintManager(wd.Manager)
+		case http.MethodPost:
+			body, rerr := ioutil.ReadAll(r.Body)
+			if rerr != nil {
+				writeError(w, http.StatusBadRequest, "invalid request body")
+				return
+			}
+			if findings, err = linter.LintJSON(body); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		default:
+			w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if findings == nil {
+			findings = []linter.Finding{}
+		}
+		writeJSON(w, http.StatusOK, findings)
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+)
+
+// Handlers factories handlers are served from, handlers of a nil factory are left out
+// every handler but the condition catalog requires Authenticate to accept the request, a nil Authenticate refuses them all
+type Handlers struct {
+	Authenticate Authenticator
+	Wardens      WardenFactory
+	RequestLogs  RequestLogFactory
+	Decisions    AuditQuerierFactory
+}
+
+// NewServeMux routes every handler:
+//
+//	GET  /conditions   condition catalog
+//	POST /permissions  effective permissions of a subject
+//	GET  /grantees     subjects allowed on a resource
+//	GET  /lint         findings on policies of a merchant, POST lints given policies
+//	POST /simulations  decisions flipped by proposed policy changes
+//	GET  /decisions    logged decisions
+func NewServeMux(h Handlers) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/conditions", ConditionCatalog())
+	if h.Wardens != nil {
+		mux.Handle("/permissions", authenticated(h.Authenticate, EffectivePermissions(h.Wardens)))
+		mux.Handle("/grantees", authenticated(h.Authenticate, Grantees(h.Wardens)))
+		mux.Handle("/lint", authenticated(h.Authenticate, LintPolicies(h.Wardens)))
+		mux.Handle("/simulations", authenticated(h.Authenticate, SimulatePolicyChanges(h.Wardens, h.RequestLogs)))
+	}
+	if h.Decisions != nil {
+		mux.Handle("/decisions", authenticated(h.Authenticate, Decisions(h.Decisions)))
+	}
+	return mux
+}
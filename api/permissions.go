@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ndv6/gate/warden"
+	"github.com/ory/ladon"
+)
+
+// WardenFactory returns the warden evaluating requests of a merchant
+type WardenFactory func(merchant string) (*warden.Warden, error)
+
+type permissionsRequest struct {
+	Merchant string        `json:"merchant"`
+	Subject  string        `json:"subject"`
+	Context  ladon.Context `json:"context"`
+}
+
+// EffectivePermissions serves resource and action pairs a subject is allowed on at a merchant
+// it takes a JSON body of `{"merchant": .., "subject": .., "context": ..}`, context is optional
+func EffectivePermissions(wardens WardenFactory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+
+		var req permissionsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if req.Merchant == "" || req.Subject == "" {
+			writeError(w, http.StatusBadRequest, "merchant and subject are required")
+			return
+		}
+		if !validMerchant(w, req.Merchant) {
+			return
+		}
+
+		wd, err := wardens(req.Merchant)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		permissions, err := wd.EffectivePermissions(req.Subject, req.Context)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, permissions)
+	}
+}
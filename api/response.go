@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+)
+
+// merchantPattern merchants are named after, they prefix collection names
+var merchantPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, &errorResponse{Error: message})
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
+}
+
+// validMerchant writes a bad request unless merchant is a valid merchant name
+func validMerchant(w http.ResponseWriter, merchant string) bool {
+	if merchantPattern.MatchString(merchant) {
+		return true
+	}
+	writeError(w, http.StatusBadRequest, "invalid merchant")
+	return false
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ndv6/gate/internal/modules/simulation"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRecentRequests = 1000
+)
+
+// RequestLog returns requests recently evaluated, most recent first
+type RequestLog = simulation.RequestLog
+
+// RequestLogFactory returns the log of requests recently evaluated for a merchant
+type RequestLogFactory func(merchant string) (RequestLog, error)
+
+type simulationRequest struct {
+	Merchant string               `json:"merchant"`
+	Changes  simulation.ChangeSet `json:"changes"`
+	Requests []ladon.Request      `json:"requests"`
+	Recent   int                  `json:"recent"`
+}
+
+// SimulatePolicyChanges serves decisions which would flip if proposed policy changes were applied
+// it takes a JSON body of `{"merchant": .., "changes": {"create": .., "update": .., "delete": ..}, "requests": ..}`,
+// without requests the `recent` (defaults to 1000) most recently logged requests are evaluated, logs may be nil
+func SimulatePolicyChanges(wardens WardenFactory, logs RequestLogFactory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+
+		var req simulationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if req.Merchant == "" {
+			writeError(w, http.StatusBadRequest, "merchant is required")
+			return
+		}
+		if !validMerchant(w, req.Merchant) {
+			return
+		}
+
+		wd, err := wardens(req.Merchant)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		requests := req.Requests
+		if len(requests) == 0 {
+			if logs == nil {
+				writeError(w, http.StatusBadRequest, "requests are required")
+				return
+			}
+			if req.Recent <= 0 {
+				req.Recent = defaultRecentRequests
+			}
+			log, err := logs(req.Merchant)
+			if err == nil {
+				requests, err = log.RecentRequests(req.Recent)
+			}
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		report, err := simulation.Simulate(wd, &req.Changes, requests)
+		if errors.Cause(err) == simulation.ErrChangeInvalid {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	}
+}
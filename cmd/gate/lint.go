@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ndv6/gate/internal/modules/linter"
+	"github.com/pkg/errors"
+)
+
+func lint(args []string) error {
+	var (
+		src    source
+		asJSON bool
+		fs     = flag.NewFlagSet("lint", flag.ExitOnError)
+	)
+	src.register(fs)
+	fs.BoolVar(&asJSON, "json", false, "print findings as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := src.validate(); err != nil {
+		return err
+	}
+
+	var (
+		findings []linter.Finding
+		err      error
+	)
+	if src.file != "" {
+		data, rerr := ioutil.ReadFile(src.file)
+		if rerr != nil {
+			return errors.WithStack(rerr)
+		}
+		findings, err = linter.LintJSON(data)
+	} else {
+		m, merr := src.manager()
+		if merr != nil {
+			return merr
+		}
+		findings, err = linter.LintManager(m)
+	}
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		if err := json.NewEncoder(os.Stdout).Encode(findings); err != nil {
+			return errors.WithStack(err)
+		}
+	} else {
+		for _, f := range findings {
+			fmt.Printf("%-7s %s [%s] %s\n", f.Severity, f.Policy, f.Kind, f.Message)
+		}
+	}
+
+	if linter.HasErrors(findings) {
+		return errors.New("policies contain errors")
+	}
+	return nil
+}
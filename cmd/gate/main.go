@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	// registers conditions shipped with gate
+	_ "github.com/ndv6/gate/internal/modules/conditions"
+)
+
+const usage = `usage: gate <command> [flags]
+
+commands:
+  lint    analyze policies of a merchant or a file
+  test    evaluate test cases against policies of a merchant or a file
+  replay  replay past decisions against policies of a merchant or a file
+  serve   serve the HTTP API on policies and decisions stored in mongodb
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "lint":
+		err = lint(os.Args[2:])
+	case "test":
+		err = test(os.Args[2:])
+	case "replay":
+		err = replay(os.Args[2:])
+	case "serve":
+		err = serve(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gate %s: %s\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ndv6/gate/internal/modules/audit"
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ndv6/gate/internal/modules/simulation"
+	"github.com/ndv6/gate/policytest"
+	"github.com/ndv6/gate/warden"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+	driver "go.mongodb.org/mongo-driver/mongo"
+)
+
+func replay(args []string) error {
+	var (
+		src       source
+		log       string
+		decisions string
+		from, to  int64
+		separator string
+		asJSON    bool
+		fs        = flag.NewFlagSet("replay", flag.ExitOnError)
+	)
+	src.register(fs)
+	fs.StringVar(&log, "log", "", "JSON lines file of past decisions, - reads standard input")
+	fs.StringVar(&decisions, "decisions", "", "merchant whose decisions logged in mongodb are replayed when -log is not given, defaults to -merchant")
+	fs.Int64Var(&from, "from", 0, "unix time logged decisions are replayed from")
+	fs.Int64Var(&to, "to", 0, "unix time logged decisions are replayed until")
+	fs.StringVar(&separator, "separator", "", "resource separator enabling hierarchical resources")
+	fs.BoolVar(&asJSON, "json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := src.validate(); err != nil {
+		return err
+	}
+	if decisions == "" {
+		decisions = src.merchant
+	}
+	if log == "" && decisions == "" {
+		return errors.New("either -log or -decisions is required")
+	}
+
+	var (
+		db        *driver.Database
+		w         *warden.Warden
+		candidate ladon.Manager
+		records   simulation.RecordIterator
+		err       error
+	)
+	// memberships and action groups are resolved at the merchant whose decisions are replayed
+	if decisions != "" {
+		if db, err = src.db(); err != nil {
+			return err
+		}
+		w = warden.NewMongoWarden(decisions, db, separator, nil)
+	}
+
+	if src.file != "" {
+		if candidate, err = policytest.LoadPolicies(src.file); err != nil {
+			return err
+		}
+	} else {
+		m := policies.NewMongoPolicyManager(src.merchant, db)
+		m.UseResourceSeparator(separator)
+		candidate = m
+	}
+	if w == nil {
+		w = warden.NewWarden(candidate, nil)
+		w.ResourceSeparator = separator
+	}
+
+	if log != "" {
+		var in io.Reader = os.Stdin
+		if log != "-" {
+			f, err := os.Open(log)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			defer f.Close()
+			in = f
+		}
+		records = simulation.NewJSONRecordReader(in)
+	} else {
+		records, err = audit.NewMongoAuditStore(decisions, db).Records(audit.Filter{From: from, To: to})
+		if err != nil {
+			return err
+		}
+	}
+
+	report, err := simulation.Replay(w, candidate, records)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		return errors.WithStack(json.NewEncoder(os.Stdout).Encode(report))
+	}
+	fmt.Printf("%d replayed, %d flipped\n", report.Replayed, report.Flipped)
+	for _, section := range []struct {
+		name   string
+		groups []simulation.FlipGroup
+	}{
+		{"subject", report.BySubject},
+		{"resource", report.ByResource},
+		{"policy", report.ByPolicy},
+	} {
+		if len(section.groups) == 0 {
+			continue
+		}
+		fmt.Printf("\nby %s:\n", section.name)
+		for _, g := range section.groups {
+			fmt.Printf("  %-40s flipped %d (granted %d, revoked %d)\n", g.Key, g.Flipped, g.Granted, g.Revoked)
+		}
+	}
+	return nil
+}
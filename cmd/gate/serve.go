@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ndv6/gate/api"
+	"github.com/ndv6/gate/internal/modules/audit"
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ndv6/gate/platform/mongo"
+	"github.com/ndv6/gate/warden"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	shutdownTimeout = 10 * time.Second
+	// maxCachedWardens bounds wardens kept in memory, one per merchant
+	maxCachedWardens = 1000
+)
+
+func serve(args []string) error {
+	var (
+		addr      string
+		mongoURL  string
+		database  string
+		separator string
+		token     string
+		fs        = flag.NewFlagSet("serve", flag.ExitOnError)
+	)
+	fs.StringVar(&addr, "addr", ":8080", "address to listen on")
+	fs.StringVar(&mongoURL, "mongo", os.Getenv("MONGO_URL"), "mongodb url, defaults to MONGO_URL")
+	fs.StringVar(&database, "db", "onelabs", "mongodb database")
+	fs.StringVar(&separator, "separator", "", "resource separator enabling hierarchical resources")
+	fs.StringVar(&token, "token", os.Getenv("GATE_API_TOKEN"), "bearer token callers authenticate with, defaults to GATE_API_TOKEN")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if token == "" {
+		return errors.New("serve: -token or GATE_API_TOKEN is required")
+	}
+
+	c, err := mongo.MongoConnect(mongoURL)
+	if err != nil {
+		return err
+	}
+	db := c.Database(database)
+	wardens := &wardenCache{db: db, separator: separator, wardens: make(map[string]*warden.Warden)}
+
+	srv := &http.Server{
+		Addr: addr,
+		Handler: api.NewServeMux(api.Handlers{
+			Authenticate: api.BearerToken(token),
+			Wardens:      wardens.get,
+			RequestLogs: func(merchant string) (api.RequestLog, error) {
+				return audit.NewMongoAuditStore(merchant, db), nil
+			},
+			Decisions: func(merchant string) (api.AuditQuerier, error) {
+				return audit.NewMongoAuditStore(merchant, db), nil
+			},
+		}),
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		done <- srv.Shutdown(ctx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "gate serve: listening on %s\n", addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return errors.WithStack(err)
+	}
+	if err := <-done; err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(c.Disconnect(context.Background()))
+}
+
+// wardenCache keeps one warden per known merchant evaluating policies stored in mongo
+// an arbitrary warden is dropped once maxCachedWardens are kept
+type wardenCache struct {
+	db        *driver.Database
+	separator string
+	mu        sync.Mutex
+	wardens   map[string]*warden.Warden
+}
+
+func (wc *wardenCache) get(merchant string) (*warden.Warden, error) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	if w, ok := wc.wardens[merchant]; ok {
+		return w, nil
+	}
+
+	names, err := wc.db.ListCollectionNames(context.Background(), bson.D{{Key: "name", Value: merchant + policies.PolicyTableSuffix}})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(names) == 0 {
+		return nil, errors.Errorf("unknown merchant %s", merchant)
+	}
+
+	if len(wc.wardens) >= maxCachedWardens {
+		for m := range wc.wardens {
+			delete(wc.wardens, m)
+			break
+		}
+	}
+	w := warden.NewMongoWarden(merchant, wc.db, wc.separator, nil)
+	wc.wardens[merchant] = w
+	return w, nil
+}
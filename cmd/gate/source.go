@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ndv6/gate/platform/mongo"
+	"github.com/pkg/errors"
+	driver "go.mongodb.org/mongo-driver/mongo"
+)
+
+// source flags selecting where policies are loaded from
+type source struct {
+	mongoURL string
+	database string
+	merchant string
+	file     string
+}
+
+func (s *source) register(fs *flag.FlagSet) {
+	fs.StringVar(&s.mongoURL, "mongo", os.Getenv("MONGO_URL"), "mongodb url, defaults to MONGO_URL")
+	fs.StringVar(&s.database, "db", "onelabs", "mongodb database")
+	fs.StringVar(&s.merchant, "merchant", "", "merchant whose policies are loaded from mongodb")
+	fs.StringVar(&s.file, "file", "", "file of policies, used instead of mongodb")
+}
+
+func (s *source) validate() error {
+	if (s.merchant == "") == (s.file == "") {
+		return errors.New("either -merchant or -file is required")
+	}
+	return nil
+}
+
+// manager connects to mongodb and returns the policy manager of the merchant
+func (s *source) manager() (*policies.MongoPolicyManager, error) {
+	db, err := s.db()
+	if err != nil {
+		return nil, err
+	}
+	return policies.NewMongoPolicyManager(s.merchant, db), nil
+}
+
+// db connects to mongodb and returns the database policies are stored in
+func (s *source) db() (*driver.Database, error) {
+	c, err := mongo.MongoConnect(s.mongoURL)
+	if err != nil {
+		return nil, err
+	}
+	return c.Database(s.database), nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/ndv6/gate/policytest"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+func test(args []string) error {
+	var (
+		src     source
+		cases   string
+		verbose bool
+		asJSON  bool
+		fs      = flag.NewFlagSet("test", flag.ExitOnError)
+	)
+	src.register(fs)
+	fs.StringVar(&cases, "cases", "", "YAML or JSON file of test cases")
+	fs.BoolVar(&verbose, "v", false, "print passed cases as well")
+	fs.BoolVar(&asJSON, "json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := src.validate(); err != nil {
+		return err
+	}
+	if cases == "" {
+		return errors.New("-cases is required")
+	}
+
+	suite, err := policytest.LoadSuite(cases)
+	if err != nil {
+		return err
+	}
+
+	var m ladon.Manager
+	if src.file != "" {
+		m, err = policytest.LoadPolicies(src.file)
+	} else {
+		m, err = src.manager()
+	}
+	if err != nil {
+		return err
+	}
+
+	report := policytest.Run(m, suite)
+	if asJSON {
+		err = errors.WithStack(json.NewEncoder(os.Stdout).Encode(report))
+	} else {
+		err = report.Write(os.Stdout, verbose)
+	}
+	if err != nil {
+		return err
+	}
+
+	if report.Failed > 0 {
+		return errors.Errorf("%d of %d cases failed", report.Failed, len(report.Results))
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// Membership data model, Member belongs to Group
+// members are users or groups, groups are groups or roles i.e. `users:42` → `groups:administrators`
+type Membership struct {
+	ID     string `json:"id" bson:"_id" db:"id"`
+	Member string `json:"member" bson:"member" db:"member"`
+	Group  string `json:"group" bson:"group" db:"group"`
+
+	CreatedAt int64 `json:"created_at" bson:"created_at" db:"created_at"` // created at - unix timestamp
+}
+
+// NewMembership created new membership
+func NewMembership(member, group string) *Membership {
+	return &Membership{
+		Member:    member,
+		Group:     group,
+		CreatedAt: time.Now().Unix(),
+	}
+}
@@ -0,0 +1,126 @@
+package actions
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMaxDepth limits how many levels of nested groups are expanded
+	DefaultMaxDepth = 8
+)
+
+var (
+	// ErrGroupInvalidParameter is ...
+	ErrGroupInvalidParameter = errors.New("action group contains invalid parameter")
+
+	// ErrGroupCycle is returned when a group would transitively include itself
+	ErrGroupCycle = errors.New("action group would include itself")
+
+	// ErrGroupNotFound is ...
+	ErrGroupNotFound = errors.New("requested action group could not be found")
+)
+
+// Group names a set of actions referenced in policies instead of listing them, i.e. `write` → create, update, delete
+// a group with a single action is an alias, groups may include other groups
+type Group struct {
+	Name        string   `json:"name" bson:"_id"`
+	Description string   `json:"description" bson:"description"`
+	Actions     []string `json:"actions" bson:"actions"`
+}
+
+// Validate checks name and actions of the group
+func (g *Group) Validate() error {
+	if g.Name == "" {
+		return errors.Wrap(ErrGroupInvalidParameter, "group requires a name")
+	}
+	if len(g.Actions) == 0 {
+		return errors.Wrapf(ErrGroupInvalidParameter, "group %s requires at least one action", g.Name)
+	}
+	for _, a := range g.Actions {
+		if a == "" {
+			return errors.Wrapf(ErrGroupInvalidParameter, "group %s contains an empty action", g.Name)
+		}
+	}
+	return nil
+}
+
+// Store persists action groups of a merchant
+type Store interface {
+	Save(g *Group) error
+	Delete(name string) error
+	FindGroups(actions ...string) ([]string, error)
+}
+
+// Resolver expands actions into the groups including them
+type Resolver struct {
+	Store    Store
+	MaxDepth int
+}
+
+// NewResolver is ...
+func NewResolver(s Store) *Resolver {
+	return &Resolver{Store: s, MaxDepth: DefaultMaxDepth}
+}
+
+// Define creates or replaces the group, refusing groups which would include themselves
+func (r *Resolver) Define(g *Group) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	including, err := r.Expand(g.Name)
+	if err != nil {
+		return err
+	}
+	for _, a := range g.Actions {
+		for _, i := range including {
+			if a == i {
+				return errors.Wrapf(ErrGroupCycle, "group %s includes %s", g.Name, a)
+			}
+		}
+	}
+
+	return r.Store.Save(g)
+}
+
+// Remove group
+func (r *Resolver) Remove(name string) error {
+	return r.Store.Delete(name)
+}
+
+// Expand returns the action followed by every group transitively including it, nearest first
+// a policy listing any of them applies to the action
+func (r *Resolver) Expand(action string) ([]string, error) {
+	var (
+		expanded = []string{action}
+		visited  = map[string]bool{action: true}
+		level    = []string{action}
+	)
+
+	for depth := 0; len(level) > 0 && depth < r.maxDepth(); depth++ {
+		groups, err := r.Store.FindGroups(level...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed expanding action %s", action)
+		}
+
+		next := make([]string, 0, len(groups))
+		for _, g := range groups {
+			if visited[g] {
+				continue
+			}
+			visited[g] = true
+			expanded = append(expanded, g)
+			next = append(next, g)
+		}
+		level = next
+	}
+
+	return expanded, nil
+}
+
+func (r *Resolver) maxDepth() int {
+	if r.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return r.MaxDepth
+}
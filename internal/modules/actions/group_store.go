@@ -0,0 +1,67 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// GroupTableSuffix ...
+	GroupTableSuffix = "_action_groups"
+)
+
+// MongoGroupStore is ...
+type MongoGroupStore struct {
+	db *mongo.Collection
+}
+
+// NewMongoGroupStore is ...
+func NewMongoGroupStore(merchant string, db *mongo.Database) *MongoGroupStore {
+	return &MongoGroupStore{db: db.Collection(fmt.Sprintf("%s%s", merchant, GroupTableSuffix))}
+}
+
+// Save creates or replaces the group
+func (gs *MongoGroupStore) Save(g *Group) error {
+	opt := options.Replace().SetUpsert(true)
+	if _, err := gs.db.ReplaceOne(context.TODO(), bson.M{"_id": g.Name}, g, opt); err != nil {
+		return errors.Wrapf(err, "failed saving action group %s", g.Name)
+	}
+	return nil
+}
+
+// Delete is ...
+func (gs *MongoGroupStore) Delete(name string) error {
+	r, err := gs.db.DeleteOne(context.TODO(), bson.M{"_id": name})
+	if err != nil {
+		return errors.Wrap(err, "failed deleting action group")
+	}
+	if r.DeletedCount == 0 {
+		return errors.Wrapf(ErrGroupNotFound, "action group %s does not exists", name)
+	}
+	return nil
+}
+
+// FindGroups returns names of groups directly including any of given actions
+func (gs *MongoGroupStore) FindGroups(actions ...string) ([]string, error) {
+	if len(actions) == 0 {
+		return nil, nil
+	}
+
+	names, err := gs.db.Distinct(context.TODO(), "_id", bson.M{"actions": bson.M{"$in": actions}})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed retrieving action groups")
+	}
+
+	var groups []string
+	for _, n := range names {
+		if s, ok := n.(string); ok {
+			groups = append(groups, s)
+		}
+	}
+	return groups, nil
+}
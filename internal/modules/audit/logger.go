@@ -0,0 +1,291 @@
+package audit
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// CallerKey request context key holding the service or user which asked for the decision
+	CallerKey = "caller"
+
+	defaultBufferSize    = 4096
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+	defaultBlockTimeout  = 10 * time.Millisecond
+)
+
+// Entry persisted authorization decision, Latency is in microseconds and CreatedAt a unix timestamp
+type Entry struct {
+	ID        string        `json:"id" bson:"_id"`
+	Merchant  string        `json:"merchant" bson:"merchant"`
+	Caller    string        `json:"caller,omitempty" bson:"caller,omitempty"`
+	Request   ladon.Request `json:"request" bson:"request"`
+	Outcome   string        `json:"outcome" bson:"outcome"`
+	Allowed   bool          `json:"allowed" bson:"allowed"`
+	Deciders  []string      `json:"deciders" bson:"deciders"`
+	Matched   []string      `json:"matched" bson:"matched"`
+	Latency   int64         `json:"latency" bson:"latency"`
+	CreatedAt int64         `json:"created_at" bson:"created_at"`
+}
+
+// Sink persists batches of entries
+type Sink interface {
+	Write(entries []*Entry) error
+}
+
+// Options tunes the writer of an AuditLogger, zero values fall back to defaults
+// BufferSize bounds entries waiting to be written, BatchSize entries written at once
+// FlushInterval is the longest an entry waits for its batch to fill
+// BlockTimeout is how long a decision waits for room in a full buffer before its entry is dropped
+type Options struct {
+	BufferSize    int
+	BatchSize     int
+	FlushInterval time.Duration
+	BlockTimeout  time.Duration
+}
+
+// Stats counters of an AuditLogger, Failed counts entries lost on encoding or sink errors
+type Stats struct {
+	Written int64 `json:"written"`
+	Dropped int64 `json:"dropped"`
+	Failed  int64 `json:"failed"`
+}
+
+// AuditLogger persists every decision of a merchant warden asynchronously
+// it fulfills ladon.AuditLogger and warden.DecisionLogger, Close must be called to flush pending entries
+type AuditLogger struct {
+	merchant string
+	sink     Sink
+	opts     Options
+	entries  chan *Entry
+	done     chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+
+	written int64
+	dropped int64
+	failed  int64
+}
+
+// NewAuditLogger starts the writer of merchant decisions to sink
+func NewAuditLogger(merchant string, sink Sink, opts Options) *AuditLogger {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultBufferSize
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.BlockTimeout <= 0 {
+		opts.BlockTimeout = defaultBlockTimeout
+	}
+
+	l := &AuditLogger{
+		merchant: merchant,
+		sink:     sink,
+		opts:     opts,
+		entries:  make(chan *Entry, opts.BufferSize),
+		done:     make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// LogDecision records a decision made by the warden
+func (l *AuditLogger) LogDecision(r *ladon.Request, d *warden.Decision, latency time.Duration) {
+	e, err := l.entry(r, d.Outcome(), d.Allowed, d.Deciders)
+	if err != nil {
+		atomic.AddInt64(&l.failed, 1)
+		return
+	}
+	e.Matched = append([]string{}, d.Matched...)
+	e.Latency = int64(latency / time.Microsecond)
+	l.enqueue(e)
+}
+
+// LogRejectedAccessRequest records a decision made by a plain ladon warden
+func (l *AuditLogger) LogRejectedAccessRequest(r *ladon.Request, pool ladon.Policies, deciders ladon.Policies) {
+	l.log(r, warden.OutcomeDeny, false, policyIDs(deciders))
+}
+
+// LogGrantedAccessRequest records a decision made by a plain ladon warden
+func (l *AuditLogger) LogGrantedAccessRequest(r *ladon.Request, pool ladon.Policies, deciders ladon.Policies) {
+	l.log(r, warden.OutcomeAllow, true, policyIDs(deciders))
+}
+
+// Stats returns counters of the writer
+func (l *AuditLogger) Stats() Stats {
+	return Stats{
+		Written: atomic.LoadInt64(&l.written),
+		Dropped: atomic.LoadInt64(&l.dropped),
+		Failed:  atomic.LoadInt64(&l.failed),
+	}
+}
+
+// Close stops accepting decisions and waits for pending entries to be written
+func (l *AuditLogger) Close() {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.entries)
+	}
+	l.mu.Unlock()
+	<-l.done
+}
+
+func (l *AuditLogger) log(r *ladon.Request, outcome string, allowed bool, deciders []string) {
+	e, err := l.entry(r, outcome, allowed, deciders)
+	if err != nil {
+		atomic.AddInt64(&l.failed, 1)
+		return
+	}
+	l.enqueue(e)
+}
+
+// entry copies the request, its context is copied right away as callers may modify it once decided
+func (l *AuditLogger) entry(r *ladon.Request, outcome string, allowed bool, deciders []string) (*Entry, error) {
+	context, err := copyContext(r.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Entry{
+		ID:        primitive.NewObjectID().Hex(),
+		Merchant:  l.merchant,
+		Request:   *r,
+		Outcome:   outcome,
+		Allowed:   allowed,
+		Deciders:  append([]string{}, deciders...),
+		CreatedAt: time.Now().Unix(),
+	}
+	e.Request.Context = context
+	if caller, ok := context[CallerKey].(string); ok {
+		e.Caller = caller
+	}
+	return e, nil
+}
+
+// copyContext deep copies the context keeping value types so logged requests are decided as they were,
+// a context which can not be persisted fails right away
+func copyContext(c ladon.Context) (ladon.Context, error) {
+	if c == nil {
+		return nil, nil
+	}
+	if _, err := bson.Marshal(c); err != nil {
+		return nil, errors.Wrap(err, "failed encoding request context")
+	}
+	return copyValue(c).(ladon.Context), nil
+}
+
+// copyValue copies maps and slices of the context recursively, other values are immutable or kept as given
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case ladon.Context:
+		copied := make(ladon.Context, len(v))
+		for k, e := range v {
+			copied[k] = copyValue(e)
+		}
+		return copied
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			copied[k] = copyValue(e)
+		}
+		return copied
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		copied := make([]interface{}, len(v))
+		for i, e := range v {
+			copied[i] = copyValue(e)
+		}
+		return copied
+	case []string:
+		if v == nil {
+			return v
+		}
+		copied := make([]string, len(v))
+		copy(copied, v)
+		return copied
+	}
+	return v
+}
+
+// enqueue hands the entry to the writer, waiting at most BlockTimeout when the buffer is full
+func (l *AuditLogger) enqueue(e *Entry) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		atomic.AddInt64(&l.dropped, 1)
+		return
+	}
+
+	select {
+	case l.entries <- e:
+		return
+	default:
+	}
+
+	t := time.NewTimer(l.opts.BlockTimeout)
+	defer t.Stop()
+	select {
+	case l.entries <- e:
+	case <-t.C:
+		atomic.AddInt64(&l.dropped, 1)
+	}
+}
+
+func (l *AuditLogger) run() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Entry, 0, l.opts.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := l.sink.Write(batch); err != nil {
+			atomic.AddInt64(&l.failed, int64(len(batch)))
+		} else {
+			atomic.AddInt64(&l.written, int64(len(batch)))
+		}
+		batch = make([]*Entry, 0, l.opts.BatchSize)
+	}
+
+	for {
+		select {
+		case e, ok := <-l.entries:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= l.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func policyIDs(policies ladon.Policies) []string {
+	ids := make([]string, 0, len(policies))
+	for _, p := range policies {
+		ids = append(ids, p.GetID())
+	}
+	return ids
+}
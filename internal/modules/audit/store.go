@@ -0,0 +1,241 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ndv6/gate/internal/modules/simulation"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// DecisionTableSuffix ...
+	DecisionTableSuffix = "_decisions"
+
+	// DefaultQueryLimit is used when a filter has no limit
+	DefaultQueryLimit = 100
+
+	// MaxQueryLimit bounds decisions returned at once, larger limits are lowered to it
+	MaxQueryLimit = 1000
+)
+
+// Filter of logged decisions, empty fields match everything
+// Decision is either a warden outcome or empty, From and To are inclusive unix timestamps
+type Filter struct {
+	Subject  string `json:"subject"`
+	Resource string `json:"resource"`
+	Decision string `json:"decision"`
+	From     int64  `json:"from"`
+	To       int64  `json:"to"`
+	Limit    int64  `json:"limit"`
+	Offset   int64  `json:"offset"`
+}
+
+// Querier retrieves logged decisions, most recent first
+type Querier interface {
+	Query(f Filter) ([]Entry, error)
+}
+
+// MongoAuditStore is ...
+type MongoAuditStore struct {
+	db *mongo.Collection
+}
+
+// NewMongoAuditStore is ...
+func NewMongoAuditStore(merchant string, db *mongo.Database) *MongoAuditStore {
+	return &MongoAuditStore{db: db.Collection(fmt.Sprintf("%s%s", merchant, DecisionTableSuffix))}
+}
+
+// EnsureIndexes creates indexes backing decision queries
+func (s *MongoAuditStore) EnsureIndexes() error {
+	models := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "request.subject", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "request.resource", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "outcome", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+	}
+	if _, err := s.db.Indexes().CreateMany(context.TODO(), models); err != nil {
+		return errors.Wrap(err, "failed creating decision indexes")
+	}
+	return nil
+}
+
+// Write batch of entries, fulfills Sink
+func (s *MongoAuditStore) Write(entries []*Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		docs = append(docs, e)
+	}
+	if _, err := s.db.InsertMany(context.TODO(), docs, options.InsertMany().SetOrdered(false)); err != nil {
+		return errors.Wrap(err, "failed writing decisions")
+	}
+	return nil
+}
+
+// Query logged decisions matching the filter, most recent first
+func (s *MongoAuditStore) Query(f Filter) ([]Entry, error) {
+	if f.Limit <= 0 {
+		f.Limit = DefaultQueryLimit
+	}
+	if f.Limit > MaxQueryLimit {
+		f.Limit = MaxQueryLimit
+	}
+
+	opt := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(f.Limit).
+		SetSkip(f.Offset)
+	c, err := s.db.Find(context.TODO(), query(f), opt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed querying decisions")
+	}
+
+	entries := make([]Entry, 0)
+	if err := c.All(context.TODO(), &entries); err != nil {
+		return nil, errors.Wrap(err, "failed decoding decisions")
+	}
+	return entries, nil
+}
+
+// RecentRequests returns requests of the most recent decisions, fulfills simulation.RequestLog
+func (s *MongoAuditStore) RecentRequests(limit int) ([]ladon.Request, error) {
+	entries, err := s.Query(Filter{Limit: int64(limit)})
+	if err != nil {
+		return nil, err
+	}
+
+	requests := make([]ladon.Request, 0, len(entries))
+	for _, e := range entries {
+		requests = append(requests, e.Request)
+	}
+	return requests, nil
+}
+
+// Records streams decisions matching the filter for replays, the filter limit is ignored
+func (s *MongoAuditStore) Records(f Filter) (simulation.RecordIterator, error) {
+	opt := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	if f.Offset > 0 {
+		opt.SetSkip(f.Offset)
+	}
+	c, err := s.db.Find(context.TODO(), query(f), opt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed querying decisions")
+	}
+	return &cursorRecords{c: c}, nil
+}
+
+// Record converts the entry into a replayable record
+func (e *Entry) Record() *simulation.Record {
+	return &simulation.Record{
+		Request:  e.Request,
+		Outcome:  e.Outcome,
+		Deciders: e.Deciders,
+		Time:     e.CreatedAt,
+	}
+}
+
+// UnmarshalBSON decodes a stored entry restoring context values to the types requests are decided with,
+// embedded documents become maps and arrays slices, arrays of strings are restored as []string
+func (e *Entry) UnmarshalBSON(data []byte) error {
+	type stored Entry
+	var decoded stored
+	if err := bson.Unmarshal(data, &decoded); err != nil {
+		return errors.WithStack(err)
+	}
+	*e = Entry(decoded)
+	if e.Request.Context != nil {
+		ctx := make(ladon.Context, len(e.Request.Context))
+		for k, v := range e.Request.Context {
+			ctx[k] = storedValue(v)
+		}
+		e.Request.Context = ctx
+	}
+	return nil
+}
+
+func storedValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case primitive.D:
+		return storedValue(v.Map())
+	case primitive.M:
+		return storedValue(map[string]interface{}(v))
+	case ladon.Context:
+		return storedValue(map[string]interface{}(v))
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = storedValue(e)
+		}
+		return m
+	case primitive.A:
+		strs := make([]string, 0, len(v))
+		values := make([]interface{}, len(v))
+		for i, e := range v {
+			values[i] = storedValue(e)
+			if s, ok := e.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		if len(v) > 0 && len(strs) == len(v) {
+			return strs
+		}
+		return values
+	}
+	return v
+}
+
+func query(f Filter) bson.M {
+	q := bson.M{}
+	if f.Subject != "" {
+		q["request.subject"] = f.Subject
+	}
+	if f.Resource != "" {
+		q["request.resource"] = f.Resource
+	}
+	if f.Decision != "" {
+		q["outcome"] = f.Decision
+	}
+
+	created := bson.M{}
+	if f.From > 0 {
+		created["$gte"] = f.From
+	}
+	if f.To > 0 {
+		created["$lte"] = f.To
+	}
+	if len(created) > 0 {
+		q["created_at"] = created
+	}
+	return q
+}
+
+type cursorRecords struct {
+	c *mongo.Cursor
+}
+
+func (r *cursorRecords) Next() (*simulation.Record, error) {
+	if !r.c.Next(context.TODO()) {
+		err := r.c.Err()
+		_ = r.c.Close(context.TODO())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed reading decisions")
+		}
+		return nil, io.EOF
+	}
+
+	var e Entry
+	if err := r.c.Decode(&e); err != nil {
+		return nil, errors.Wrap(err, "failed decoding decision")
+	}
+	return e.Record(), nil
+}
@@ -0,0 +1,124 @@
+package conditions
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+const (
+	// AuthTimeKey context key holding the unix time the caller authenticated at
+	AuthTimeKey = "auth_time"
+
+	// AuthMethodsKey context key holding the authentication methods references i.e. ["pwd", "otp"]
+	AuthMethodsKey = "amr"
+
+	// authClockSkew tolerated difference between the caller clock and ours
+	authClockSkew = 60
+)
+
+// AuthFreshness match when the caller authenticated within the last MaxAge seconds using
+// every one of Methods, or any one of them when AnyMethod is set
+// values are read from the `auth_time` and `amr` context keys, a failure signals that
+// the caller has to authenticate again (step-up) rather than being plainly denied
+type AuthFreshness struct {
+	MaxAge    int64    `json:"max_age" bson:"max_age" default:"0"`
+	Methods   []string `json:"methods" bson:"methods" default:"[]"`
+	AnyMethod bool     `json:"any_method" bson:"any_method" default:"false"`
+}
+
+func init() {
+	registry.MustRegister(func() ladon.Condition {
+		return new(AuthFreshness)
+	})
+}
+
+// Validate checks age and methods
+func (c *AuthFreshness) Validate() error {
+	if c.MaxAge < 0 {
+		return errors.New("max age can not be negative")
+	}
+	if c.MaxAge == 0 && len(c.Methods) == 0 {
+		return errors.New("either max age or methods is required")
+	}
+	return nil
+}
+
+// Fulfills checking condition rule
+func (c *AuthFreshness) Fulfills(_ interface{}, r *ladon.Request) bool {
+	if c.MaxAge > 0 {
+		at, ok := unixTime(r.Context[AuthTimeKey])
+		if !ok {
+			return false
+		}
+		now := decisionTime(r)
+		if at > now+authClockSkew || now-at > c.MaxAge {
+			return false
+		}
+	}
+
+	if len(c.Methods) == 0 {
+		return true
+	}
+	amr := make(map[string]bool)
+	switch v := r.Context[AuthMethodsKey].(type) {
+	case []string:
+		for _, m := range v {
+			amr[m] = true
+		}
+	case []interface{}:
+		for _, m := range v {
+			if s, ok := m.(string); ok {
+				amr[s] = true
+			}
+		}
+	case string:
+		amr[v] = true
+	}
+
+	for _, m := range c.Methods {
+		if amr[m] && c.AnyMethod {
+			return true
+		}
+		if !amr[m] && !c.AnyMethod {
+			return false
+		}
+	}
+	return !c.AnyMethod
+}
+
+// GetName condition
+func (c *AuthFreshness) GetName() string {
+	return "AuthFreshnessCondition"
+}
+
+// Description condition
+func (c *AuthFreshness) Description() string {
+	return "Requires a recent authentication (`auth_time`) with the given methods (`amr`), failing asks for step-up"
+}
+
+// StepUp failing this condition can be resolved by authenticating again
+func (c *AuthFreshness) StepUp() bool {
+	return true
+}
+
+// decisionTime returns the unix time the warden decides the request at, the current time outside a warden
+func decisionTime(r *ladon.Request) int64 {
+	if at, ok := unixTime(r.Context[warden.DecisionTimeKey]); ok {
+		return at
+	}
+	return time.Now().Unix()
+}
+
+func unixTime(v interface{}) (int64, bool) {
+	if s, ok := v.(string); ok {
+		n, err := strconv.ParseInt(s, 10, 64)
+		return n, err == nil
+	}
+	f, ok := toFloat(v)
+	return int64(f), ok
+}
@@ -0,0 +1,76 @@
+package conditions
+
+import (
+	"encoding/json"
+
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// bsonEnvelope stores a nested condition the same way policies store their conditions
+type bsonEnvelope struct {
+	Type    string   `bson:"type"`
+	Options bson.Raw `bson:"options"`
+}
+
+// jsonEnvelope is the JSON counterpart of bsonEnvelope as used by ladon
+type jsonEnvelope struct {
+	Type    string          `json:"type"`
+	Options json.RawMessage `json:"options"`
+}
+
+func newBSONEnvelope(c ladon.Condition) (*bsonEnvelope, error) {
+	if c == nil {
+		return nil, errors.New("nested condition is missing")
+	}
+	raw, err := bson.Marshal(c)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &bsonEnvelope{Type: c.GetName(), Options: bson.Raw(raw)}, nil
+}
+
+func (e *bsonEnvelope) condition() (ladon.Condition, error) {
+	c, err := newCondition(e.Type)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Options) == 0 {
+		return c, nil
+	}
+	if err := bson.Unmarshal(e.Options, c); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return c, nil
+}
+
+func newJSONEnvelope(c ladon.Condition) (*jsonEnvelope, error) {
+	if c == nil {
+		return nil, errors.New("nested condition is missing")
+	}
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &jsonEnvelope{Type: c.GetName(), Options: json.RawMessage(raw)}, nil
+}
+
+func (e *jsonEnvelope) condition() (ladon.Condition, error) {
+	c, err := newCondition(e.Type)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Options) == 0 {
+		return c, nil
+	}
+	if err := json.Unmarshal(e.Options, c); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return c, nil
+}
+
+func newCondition(name string) (ladon.Condition, error) {
+	return registry.Lookup(name)
+}
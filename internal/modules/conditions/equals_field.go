@@ -0,0 +1,45 @@
+package conditions
+
+import (
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+)
+
+// EqualsField match when given value equals the value of another request field
+// Field references `subject`, `resource`, `action` or a context key as `context.<key>`
+type EqualsField struct {
+	Field string `json:"field" bson:"field"`
+}
+
+func init() {
+	registry.MustRegister(func() ladon.Condition {
+		return new(EqualsField)
+	})
+}
+
+// Validate checks the field reference
+func (c *EqualsField) Validate() error {
+	return validateField(c.Field)
+}
+
+// Fulfills checking condition rule
+func (c *EqualsField) Fulfills(value interface{}, r *ladon.Request) bool {
+	if value == nil {
+		return false
+	}
+	other, ok := resolveField(c.Field, r)
+	if !ok {
+		return false
+	}
+	return equalValues(value, other)
+}
+
+// GetName condition
+func (c *EqualsField) GetName() string {
+	return "EqualsFieldCondition"
+}
+
+// Description condition
+func (c *EqualsField) Description() string {
+	return "Given value equals another request field or context path"
+}
@@ -0,0 +1,165 @@
+package conditions
+
+import (
+	"context"
+	"time"
+
+	model "github.com/ndv6/gate/internal/models"
+	"github.com/ndv6/gate/platform/mongo"
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+const (
+	eventHistoryPageSize = 100
+	eventHistoryMaxScan  = 1000
+	eventHistoryTimeout  = 2 * time.Second
+)
+
+var (
+	eventStore mongo.EventStore
+)
+
+// UseEventStore sets the store consulted by event based conditions
+func UseEventStore(s mongo.EventStore) {
+	eventStore = s
+}
+
+// EventHistory match when the user has between Min and Max events of given Actions at a merchant
+// within the last Window seconds, zero Window counts every event regardless of its time
+// i.e. "has a `register` event at this merchant in the last 30 days"
+// the merchant id is taken from the given value unless Merchant is set, the user id is taken
+// from UserField which defaults to `subject`
+type EventHistory struct {
+	Actions   []string               `json:"actions" bson:"actions"`
+	Meta      map[string]interface{} `json:"meta,omitempty" bson:"meta,omitempty"`
+	Merchant  string                 `json:"merchant,omitempty" bson:"merchant,omitempty"`
+	UserField string                 `json:"user_field,omitempty" bson:"user_field,omitempty"`
+	Min       int64                  `json:"min" bson:"min" default:"0"`
+	Max       *int64                 `json:"max,omitempty" bson:"max,omitempty"`
+	Window    int64                  `json:"window" bson:"window" default:"0"`
+}
+
+func init() {
+	registry.MustRegister(func() ladon.Condition {
+		return new(EventHistory)
+	})
+}
+
+// Validate checks counts, window and user reference
+func (c *EventHistory) Validate() error {
+	if c.Min < 0 || (c.Max != nil && *c.Max < c.Min) {
+		return errors.New("invalid event count range")
+	}
+	if c.Max != nil && *c.Max >= eventHistoryMaxScan {
+		return errors.Errorf("max count must be lower than %d", eventHistoryMaxScan)
+	}
+	if c.Min > eventHistoryMaxScan {
+		return errors.Errorf("min count must not exceed %d", eventHistoryMaxScan)
+	}
+	if c.Window < 0 {
+		return errors.New("window can not be negative")
+	}
+	if c.UserField != "" {
+		return validateField(c.UserField)
+	}
+	return nil
+}
+
+// Fulfills checking condition rule
+func (c *EventHistory) Fulfills(value interface{}, r *ladon.Request) bool {
+	if eventStore == nil {
+		return false
+	}
+
+	userID, merchantID := eventIdentities(c.Merchant, c.UserField, value, r)
+	if userID == "" || merchantID == "" {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), eventHistoryTimeout)
+	defer cancel()
+
+	n, err := countEvents(ctx, userID, merchantID, c.Actions, c.Meta, c.Window, decisionTime(r), c.needed())
+	if err != nil {
+		return false
+	}
+	return n >= c.Min && (c.Max == nil || n <= *c.Max)
+}
+
+// GetName condition
+func (c *EventHistory) GetName() string {
+	return "EventHistoryCondition"
+}
+
+// Description condition
+func (c *EventHistory) Description() string {
+	return "User has between min and max events of given actions at the merchant within a time window"
+}
+
+// eventIdentities resolves the user from the request and the merchant from given value unless fixed
+func eventIdentities(merchant, userField string, value interface{}, r *ladon.Request) (userID, merchantID string) {
+	if userField == "" {
+		userField = FieldSubject
+	}
+	if v, ok := resolveField(userField, r); ok {
+		userID, _ = v.(string)
+	}
+
+	merchantID = merchant
+	if merchantID == "" {
+		merchantID, _ = value.(string)
+	}
+	return
+}
+
+// needed returns how many events have to be counted before the outcome is known
+func (c *EventHistory) needed() int64 {
+	if c.Max != nil {
+		return *c.Max + 1
+	}
+	return c.Min
+}
+
+// countEvents counts events within the window, it stops once enough events were found
+func countEvents(ctx context.Context, userID, merchantID string, actions []string, meta map[string]interface{}, window, now, needed int64) (int64, error) {
+	var n int64
+	if needed <= 0 {
+		return 0, nil
+	}
+	err := scanEvents(ctx, userID, merchantID, actions, meta, window, now, func(model.Event) bool {
+		n++
+		return n < needed
+	})
+	return n, err
+}
+
+// scanEvents calls fn for every event within the window ending at now, newest first, until fn returns false
+// the store returns events newest first by event time so scanning stops at the first event older than the window,
+// events emitted after now are skipped beyond the tolerated clock skew so past decisions are replayed as they were taken
+func scanEvents(ctx context.Context, userID, merchantID string, actions []string, meta map[string]interface{}, window, now int64, fn func(model.Event) bool) error {
+	var since int64
+	if window > 0 {
+		since = now - window
+	}
+
+	for skip := int64(0); skip < eventHistoryMaxScan; skip += eventHistoryPageSize {
+		events, err := eventStore.Retrieve(ctx, userID, merchantID, actions, meta, eventHistoryPageSize, skip)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if e.EventTime > now+authClockSkew {
+				continue
+			}
+			if e.EventTime < since || !fn(e) {
+				return nil
+			}
+		}
+		if len(events) < eventHistoryPageSize {
+			return nil
+		}
+	}
+	return nil
+}
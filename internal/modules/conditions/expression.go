@@ -0,0 +1,143 @@
+package conditions
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/ndv6/gate/internal/modules/expression"
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+// ExpressionEnv variables available to expression conditions
+// subject, resource and action come from the request, ctx is the whole request context
+// and value is the context value keyed by the condition
+var ExpressionEnv = expression.Env{
+	"subject":  expression.TypeString,
+	"resource": expression.TypeString,
+	"action":   expression.TypeString,
+	"ctx":      expression.TypeMap,
+	"value":    expression.TypeDyn,
+}
+
+const (
+	expressionCacheSize = 1000
+)
+
+// compiled programs shared by every condition holding the same expression
+var programs = &programCache{entries: make(map[string]*list.Element), order: list.New()}
+
+// Expression match when given sandboxed expression evaluates to true
+// i.e. `ctx.amount < 1000 && subject.startsWith("users:")`
+// MaxCost bounds the evaluation cost, zero means expression.DefaultCostLimit
+type Expression struct {
+	Expression string `json:"expression" bson:"expression"`
+	MaxCost    int    `json:"max_cost" bson:"max_cost" default:"1000"`
+}
+
+func init() {
+	registry.MustRegister(func() ladon.Condition {
+		return new(Expression)
+	})
+}
+
+// Validate compiles and type-checks the expression
+func (c *Expression) Validate() error {
+	if c.MaxCost < 0 {
+		return errors.New("max cost can not be negative")
+	}
+	_, err := c.program()
+	return err
+}
+
+// Fulfills checking condition rule
+func (c *Expression) Fulfills(value interface{}, r *ladon.Request) bool {
+	p, err := c.program()
+	if err != nil {
+		return false
+	}
+
+	ctx := map[string]interface{}(r.Context)
+	if ctx == nil {
+		ctx = map[string]interface{}{}
+	}
+	out, err := p.Eval(map[string]interface{}{
+		"subject":  r.Subject,
+		"resource": r.Resource,
+		"action":   r.Action,
+		"ctx":      ctx,
+		"value":    value,
+	}, c.MaxCost)
+	if err != nil {
+		return false
+	}
+
+	b, ok := out.(bool)
+	return ok && b
+}
+
+// GetName condition
+func (c *Expression) GetName() string {
+	return "ExpressionCondition"
+}
+
+// Description condition
+func (c *Expression) Description() string {
+	return "Sandboxed expression over subject, resource, action and context evaluates to true"
+}
+
+func (c *Expression) program() (*expression.Program, error) {
+	if p, ok := programs.get(c.Expression); ok {
+		return p, nil
+	}
+
+	p, err := expression.Compile(c.Expression, ExpressionEnv)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.ResultType(); t != expression.TypeBool && t != expression.TypeDyn {
+		return nil, errors.Errorf("expression must evaluate to bool, got %s", t)
+	}
+
+	programs.set(c.Expression, p)
+	return p, nil
+}
+
+type cachedProgram struct {
+	source  string
+	program *expression.Program
+}
+
+// programCache bounded in-memory cache of compiled programs, the least recently used one is evicted first
+type programCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+func (pc *programCache) get(source string) (*expression.Program, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	e, ok := pc.entries[source]
+	if !ok {
+		return nil, false
+	}
+	pc.order.MoveToFront(e)
+	return e.Value.(*cachedProgram).program, true
+}
+
+func (pc *programCache) set(source string, p *expression.Program) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if e, ok := pc.entries[source]; ok {
+		pc.order.MoveToFront(e)
+		return
+	}
+	pc.entries[source] = pc.order.PushFront(&cachedProgram{source: source, program: p})
+	for pc.order.Len() > expressionCacheSize {
+		oldest := pc.order.Back()
+		pc.order.Remove(oldest)
+		delete(pc.entries, oldest.Value.(*cachedProgram).source)
+	}
+}
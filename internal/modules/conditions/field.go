@@ -0,0 +1,79 @@
+package conditions
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+const (
+	// FieldSubject refers to the request subject
+	FieldSubject = "subject"
+
+	// FieldResource refers to the request resource
+	FieldResource = "resource"
+
+	// FieldAction refers to the request action
+	FieldAction = "action"
+
+	// FieldContextPrefix prefixes references into the request context i.e. `context.owner_id`
+	// nested values are selected by path i.e. `context.order.merchant.id` or `context.items[0]`
+	FieldContextPrefix = "context."
+)
+
+// validateField checks whether ref is a known request field reference
+func validateField(ref string) error {
+	switch ref {
+	case FieldSubject, FieldResource, FieldAction:
+		return nil
+	}
+	if strings.HasPrefix(ref, FieldContextPrefix) && len(ref) > len(FieldContextPrefix) {
+		_, err := parsePath(strings.TrimPrefix(ref, FieldContextPrefix))
+		return err
+	}
+	return errors.Errorf("unknown field reference %q", ref)
+}
+
+// resolveField returns the value of the request field referenced by ref
+func resolveField(ref string, r *ladon.Request) (interface{}, bool) {
+	switch ref {
+	case FieldSubject:
+		return r.Subject, true
+	case FieldResource:
+		return r.Resource, true
+	case FieldAction:
+		return r.Action, true
+	}
+	if !strings.HasPrefix(ref, FieldContextPrefix) || r.Context == nil {
+		return nil, false
+	}
+	segments, err := parsePath(strings.TrimPrefix(ref, FieldContextPrefix))
+	if err != nil {
+		return nil, false
+	}
+	return selectPath(map[string]interface{}(r.Context), segments)
+}
+
+// equalValues compares two values, numbers are compared regardless of their Go type
+func equalValues(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
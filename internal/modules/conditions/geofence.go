@@ -0,0 +1,239 @@
+package conditions
+
+import (
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GeoPoint area type, combined with Radius it describes a circle
+	GeoPoint = "Point"
+
+	// GeoPolygon area type, first ring is the boundary and following rings are holes
+	GeoPolygon = "Polygon"
+
+	earthRadius = 6371008.8 // meters
+)
+
+// GeoArea GeoJSON compatible geometry i.e. `{"type": "Polygon", "coordinates": [[[lng, lat], ...]]}`
+// a Point with Radius in meters describes a circle
+type GeoArea struct {
+	Type        string      `json:"type" bson:"type"`
+	Coordinates interface{} `json:"coordinates" bson:"coordinates"`
+	Radius      float64     `json:"radius,omitempty" bson:"radius,omitempty"`
+}
+
+// Geofence match when given location lies inside Area
+// the location is either `{"latitude": .., "longitude": ..}` (`lat`, `lng`/`lon` also accepted),
+// a GeoJSON point `{"type": "Point", "coordinates": [lng, lat]}` or a `[lng, lat]` pair
+type Geofence struct {
+	Area GeoArea `json:"area" bson:"area"`
+}
+
+func init() {
+	registry.MustRegister(func() ladon.Condition {
+		return new(Geofence)
+	})
+}
+
+// Validate checks the area geometry
+func (c *Geofence) Validate() error {
+	switch c.Area.Type {
+	case GeoPoint:
+		if _, err := c.center(); err != nil {
+			return err
+		}
+		if c.Area.Radius <= 0 {
+			return errors.New("point area requires a positive radius")
+		}
+		return nil
+	case GeoPolygon:
+		_, err := c.rings()
+		return err
+	}
+	return errors.Errorf("unsupported area type %q", c.Area.Type)
+}
+
+// Fulfills checking condition rule
+func (c *Geofence) Fulfills(value interface{}, _ *ladon.Request) bool {
+	p, ok := parseLocation(value)
+	if !ok {
+		return false
+	}
+
+	switch c.Area.Type {
+	case GeoPoint:
+		center, err := c.center()
+		if err != nil || c.Area.Radius <= 0 {
+			return false
+		}
+		return haversine(center, p) <= c.Area.Radius
+
+	case GeoPolygon:
+		rings, err := c.rings()
+		if err != nil {
+			return false
+		}
+		if !insideRing(p, rings[0]) {
+			return false
+		}
+		for _, hole := range rings[1:] {
+			if insideRing(p, hole) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// GetName condition
+func (c *Geofence) GetName() string {
+	return "GeofenceCondition"
+}
+
+// Description condition
+func (c *Geofence) Description() string {
+	return "Given location lies inside a circle or polygon"
+}
+
+func (c *Geofence) center() ([2]float64, error) {
+	p, ok := parsePosition(c.Area.Coordinates)
+	if !ok {
+		return p, errors.New("point area requires [longitude, latitude] coordinates")
+	}
+	return p, nil
+}
+
+func (c *Geofence) rings() ([][][2]float64, error) {
+	raw, ok := asSlice(c.Area.Coordinates)
+	if !ok || len(raw) == 0 {
+		return nil, errors.New("polygon area requires at least one ring")
+	}
+
+	rings := make([][][2]float64, 0, len(raw))
+	for i, r := range raw {
+		positions, ok := asSlice(r)
+		if !ok {
+			return nil, errors.Errorf("ring #%d is not a list of positions", i)
+		}
+		ring := make([][2]float64, 0, len(positions))
+		for _, v := range positions {
+			p, ok := parsePosition(v)
+			if !ok {
+				return nil, errors.Errorf("ring #%d contains an invalid position", i)
+			}
+			ring = append(ring, p)
+		}
+		if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+			ring = ring[:len(ring)-1]
+		}
+		if len(ring) < 3 {
+			return nil, errors.Errorf("ring #%d requires at least 3 distinct positions", i)
+		}
+		rings = append(rings, ring)
+	}
+	return rings, nil
+}
+
+// parseLocation reads a [longitude, latitude] position from the supported location formats
+func parseLocation(value interface{}) ([2]float64, bool) {
+	if p, ok := parsePosition(value); ok {
+		return p, true
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return [2]float64{}, false
+	}
+	fields := make(map[string]interface{}, rv.Len())
+	for _, k := range rv.MapKeys() {
+		fields[strings.ToLower(k.String())] = rv.MapIndex(k).Interface()
+	}
+
+	if coordinates, ok := fields["coordinates"]; ok {
+		return parsePosition(coordinates)
+	}
+
+	var (
+		lat, lng       float64
+		hasLat, hasLng bool
+	)
+	for _, k := range []string{"latitude", "lat"} {
+		if v, ok := fields[k]; ok {
+			lat, hasLat = toFloat(v)
+			break
+		}
+	}
+	for _, k := range []string{"longitude", "lng", "lon"} {
+		if v, ok := fields[k]; ok {
+			lng, hasLng = toFloat(v)
+			break
+		}
+	}
+	if !hasLat || !hasLng || !validPosition(lng, lat) {
+		return [2]float64{}, false
+	}
+	return [2]float64{lng, lat}, true
+}
+
+// parsePosition reads a GeoJSON position, longitude first
+func parsePosition(v interface{}) ([2]float64, bool) {
+	s, ok := asSlice(v)
+	if !ok || len(s) < 2 {
+		return [2]float64{}, false
+	}
+	lng, ok := toFloat(s[0])
+	if !ok {
+		return [2]float64{}, false
+	}
+	lat, ok := toFloat(s[1])
+	if !ok || !validPosition(lng, lat) {
+		return [2]float64{}, false
+	}
+	return [2]float64{lng, lat}, true
+}
+
+func validPosition(lng, lat float64) bool {
+	return lng >= -180 && lng <= 180 && lat >= -90 && lat <= 90
+}
+
+func asSlice(v interface{}) ([]interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	out := make([]interface{}, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out, true
+}
+
+// haversine great-circle distance in meters between two [lng, lat] positions
+func haversine(a, b [2]float64) float64 {
+	rad := math.Pi / 180
+	dLat := (b[1] - a[1]) * rad
+	dLng := (b[0] - a[0]) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(a[1]*rad)*math.Cos(b[1]*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// insideRing ray casting point-in-polygon test on the longitude/latitude plane
+func insideRing(p [2]float64, ring [][2]float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > p[1]) != (b[1] > p[1]) &&
+			p[0] < (b[0]-a[0])*(p[1]-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,137 @@
+package conditions
+
+import (
+	"net"
+	"strings"
+
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+// Network match when the client IP falls within one of Allow ranges and none of Exclude ranges
+// ranges are IPv4/IPv6 CIDRs or single addresses i.e. `10.0.0.0/8`, `2001:db8::/32`, `192.168.1.10`
+// the given value is either a single address or an `X-Forwarded-For` chain, TrustedProxies tells
+// how many right-most entries of the chain were appended by our own proxies and must be skipped,
+// a chain with no entry left of them is refused
+type Network struct {
+	Allow          []string `json:"allow" bson:"allow"`
+	Exclude        []string `json:"exclude" bson:"exclude" default:"[]"`
+	TrustedProxies int      `json:"trusted_proxies" bson:"trusted_proxies" default:"0"`
+}
+
+func init() {
+	registry.MustRegister(func() ladon.Condition {
+		return new(Network)
+	})
+}
+
+// Validate checks every configured range
+func (c *Network) Validate() error {
+	if len(c.Allow) == 0 {
+		return errors.New("at least one allowed range is required")
+	}
+	if c.TrustedProxies < 0 {
+		return errors.New("trusted proxies can not be negative")
+	}
+	if _, err := parseNetworks(c.Allow); err != nil {
+		return err
+	}
+	_, err := parseNetworks(c.Exclude)
+	return err
+}
+
+// Fulfills checking condition rule
+func (c *Network) Fulfills(value interface{}, _ *ladon.Request) bool {
+	ip := c.clientIP(value)
+	if ip == nil {
+		return false
+	}
+
+	allow, err := parseNetworks(c.Allow)
+	if err != nil {
+		return false
+	}
+	exclude, err := parseNetworks(c.Exclude)
+	if err != nil {
+		return false
+	}
+
+	for _, n := range exclude {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	for _, n := range allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetName condition
+func (c *Network) GetName() string {
+	return "NetworkCondition"
+}
+
+// Description condition
+func (c *Network) Description() string {
+	return "Client IP, optionally behind trusted proxies, is within allowed and outside excluded ranges"
+}
+
+// clientIP picks the client address out of given value skipping trusted proxies
+func (c *Network) clientIP(value interface{}) net.IP {
+	var chain []string
+	switch v := value.(type) {
+	case string:
+		chain = strings.Split(v, ",")
+	case []string:
+		for _, s := range v {
+			chain = append(chain, strings.Split(s, ",")...)
+		}
+	default:
+		return nil
+	}
+
+	// a chain not longer than trusted proxies did not go through all of them, its entries are client supplied
+	if len(chain) <= c.TrustedProxies {
+		return nil
+	}
+	return parseAddress(chain[len(chain)-1-c.TrustedProxies])
+}
+
+// parseAddress parses an IP address optionally followed by a port
+func parseAddress(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	return net.ParseIP(strings.Trim(s, "[]"))
+}
+
+func parseNetworks(ranges []string) ([]*net.IPNet, error) {
+	out := make([]*net.IPNet, 0, len(ranges))
+	for _, r := range ranges {
+		r = strings.TrimSpace(r)
+		if !strings.Contains(r, "/") {
+			ip := net.ParseIP(r)
+			if ip == nil {
+				return nil, errors.Errorf("invalid address %q", r)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, errors.Errorf("invalid range %q", r)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
@@ -0,0 +1,112 @@
+package conditions
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// pathSegment is either a map key or a list index
+type pathSegment struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+// parsePath parses a dot/JSONPath-lite selector
+// i.e. `order.merchant.id`, `$.items[0].sku`, `meta["x-key"]`, `items[-1]`
+func parsePath(path string) ([]pathSegment, error) {
+	var (
+		segments = make([]pathSegment, 0)
+		p        = strings.TrimPrefix(strings.TrimSpace(path), "$")
+		i        = 0
+	)
+
+	for i < len(p) {
+		if p[i] == '[' {
+			end := strings.IndexByte(p[i:], ']')
+			if end < 0 {
+				return nil, errors.Errorf("unterminated [ in path %q", path)
+			}
+			inner := p[i+1 : i+end]
+			i += end + 1
+
+			if len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0] {
+				segments = append(segments, pathSegment{key: inner[1 : len(inner)-1]})
+				continue
+			}
+			n, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, errors.Errorf("invalid index %q in path %q", inner, path)
+			}
+			segments = append(segments, pathSegment{index: n, isIdx: true})
+			continue
+		}
+
+		// keys are separated by dots, only the first one may omit it
+		if p[i] == '.' {
+			i++
+		} else if i > 0 {
+			return nil, errors.Errorf("unexpected %q in path %q", p[i], path)
+		}
+
+		start := i
+		for i < len(p) && p[i] != '.' && p[i] != '[' {
+			if p[i] == ']' {
+				return nil, errors.Errorf("unexpected ] in path %q", path)
+			}
+			i++
+		}
+		if start == i {
+			return nil, errors.Errorf("empty key in path %q", path)
+		}
+		segments = append(segments, pathSegment{key: p[start:i]})
+	}
+
+	return segments, nil
+}
+
+// selectPath walks given value along the segments
+func selectPath(v interface{}, segments []pathSegment) (interface{}, bool) {
+	for _, s := range segments {
+		if v == nil {
+			return nil, false
+		}
+
+		rv := reflect.ValueOf(v)
+		for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+			if rv.IsNil() {
+				return nil, false
+			}
+			rv = rv.Elem()
+		}
+
+		if s.isIdx {
+			if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+				return nil, false
+			}
+			i := s.index
+			if i < 0 {
+				i += rv.Len()
+			}
+			if i < 0 || i >= rv.Len() {
+				return nil, false
+			}
+			v = rv.Index(i).Interface()
+			continue
+		}
+
+		if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		e := rv.MapIndex(reflect.ValueOf(s.key).Convert(rv.Type().Key()))
+		if !e.IsValid() {
+			return nil, false
+		}
+		v = e.Interface()
+	}
+
+	return v, true
+}
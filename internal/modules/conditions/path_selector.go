@@ -0,0 +1,159 @@
+package conditions
+
+import (
+	"encoding/json"
+
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// PathSelector extracts a nested value from given value and delegates to another condition
+// i.e. condition keyed `order` with Path `merchant.id` checks `{"order": {"merchant": {"id": "..."}}}`
+// Path supports dots, array indexing and quoted keys i.e. `$.items[0]["sku-id"]`
+type PathSelector struct {
+	Path      string          `json:"path" bson:"path"`
+	Condition ladon.Condition `json:"condition" bson:"condition"`
+}
+
+func init() {
+	registry.MustRegister(func() ladon.Condition {
+		return new(PathSelector)
+	})
+}
+
+// Validate checks the path and the nested condition
+func (c *PathSelector) Validate() error {
+	if _, err := parsePath(c.Path); err != nil {
+		return err
+	}
+	if c.Condition == nil {
+		return errors.New("nested condition is missing")
+	}
+	if v, ok := c.Condition.(interface{ Validate() error }); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// Fulfills checking condition rule
+func (c *PathSelector) Fulfills(value interface{}, r *ladon.Request) bool {
+	v, ok := c.selectValue(value)
+	if !ok {
+		return false
+	}
+	return c.Condition.Fulfills(v, r)
+}
+
+// Explain reports the path along with details of the nested condition when it explains itself
+func (c *PathSelector) Explain(value interface{}, r *ladon.Request) (bool, map[string]interface{}) {
+	details := map[string]interface{}{"path": c.Path}
+	v, ok := c.selectValue(value)
+	if !ok {
+		details["error"] = "path selects no value"
+		return false, details
+	}
+	if e, ok := c.Condition.(explainer); ok {
+		passed, nested := e.Explain(v, r)
+		details["condition"] = nested
+		return passed, details
+	}
+	return c.Condition.Fulfills(v, r), details
+}
+
+// StepUp whether the nested condition can be resolved by authenticating again
+func (c *PathSelector) StepUp() bool {
+	s, ok := c.Condition.(interface{ StepUp() bool })
+	return ok && s.StepUp()
+}
+
+// SideEffects whether evaluating the nested condition changes state or reaches external services
+func (c *PathSelector) SideEffects() bool {
+	s, ok := c.Condition.(interface{ SideEffects() bool })
+	return ok && s.SideEffects()
+}
+
+// GetName condition
+func (c *PathSelector) GetName() string {
+	return "PathSelectorCondition"
+}
+
+// Description condition
+func (c *PathSelector) Description() string {
+	return "Selects a nested value by path and checks it with another condition"
+}
+
+// MarshalBSON stores the nested condition within a type/options envelope
+func (c *PathSelector) MarshalBSON() ([]byte, error) {
+	e, err := newBSONEnvelope(c.Condition)
+	if err != nil {
+		return nil, err
+	}
+	return bson.Marshal(struct {
+		Path      string        `bson:"path"`
+		Condition *bsonEnvelope `bson:"condition"`
+	}{c.Path, e})
+}
+
+// UnmarshalBSON restores the nested condition from its envelope
+func (c *PathSelector) UnmarshalBSON(data []byte) error {
+	var raw struct {
+		Path      string       `bson:"path"`
+		Condition bsonEnvelope `bson:"condition"`
+	}
+	if err := bson.Unmarshal(data, &raw); err != nil {
+		return errors.WithStack(err)
+	}
+	nested, err := raw.Condition.condition()
+	if err != nil {
+		return err
+	}
+	c.Path, c.Condition = raw.Path, nested
+	return nil
+}
+
+// MarshalJSON stores the nested condition within a type/options envelope
+func (c *PathSelector) MarshalJSON() ([]byte, error) {
+	e, err := newJSONEnvelope(c.Condition)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		Path      string        `json:"path"`
+		Condition *jsonEnvelope `json:"condition"`
+	}{c.Path, e})
+}
+
+// UnmarshalJSON restores the nested condition from its envelope
+func (c *PathSelector) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Path      string       `json:"path"`
+		Condition jsonEnvelope `json:"condition"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return errors.WithStack(err)
+	}
+	nested, err := raw.Condition.condition()
+	if err != nil {
+		return err
+	}
+	c.Path, c.Condition = raw.Path, nested
+	return nil
+}
+
+// explainer mirrors warden.Explainer, nested conditions explaining themselves are explained through the selector
+type explainer interface {
+	Explain(value interface{}, r *ladon.Request) (bool, map[string]interface{})
+}
+
+func (c *PathSelector) selectValue(value interface{}) (interface{}, bool) {
+	if c.Condition == nil {
+		return nil, false
+	}
+	segments, err := parsePath(c.Path)
+	if err != nil {
+		return nil, false
+	}
+	return selectPath(value, segments)
+}
@@ -0,0 +1,126 @@
+package conditions
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/go-redis/redis"
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+const (
+	// RateLimitKeyPrefix prefixes every redis key used by rate limit conditions
+	RateLimitKeyPrefix = "gate:rate:"
+)
+
+var (
+	redisClient redis.UniversalClient
+
+	// slidingWindow trims hits older than the window, then records a new hit only when the
+	// limit has not been reached yet; running as a script keeps it atomic across replicas
+	// and the redis clock keeps windows consistent whatever the clock of each replica
+	slidingWindow = redis.NewScript(`
+redis.replicate_commands()
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local window = tonumber(ARGV[1])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], now, now .. '-' .. ARGV[3])
+redis.call('PEXPIRE', KEYS[1], window)
+return 1
+`)
+)
+
+// UseRedis sets the client used by redis backed conditions
+func UseRedis(c redis.UniversalClient) {
+	redisClient = c
+}
+
+// RateLimit match while the identity performed the request action fewer than Limit times
+// within the last Window seconds, every fulfilled check counts as one hit
+// the identity is taken from KeyField which defaults to `subject` i.e. `context.device_id`
+// Name optionally separates counters of different policies sharing the same action
+// counters are kept per merchant, the merchant id is taken from the given value unless Merchant is set
+type RateLimit struct {
+	Limit    int64  `json:"limit" bson:"limit"`
+	Window   int64  `json:"window" bson:"window"`
+	KeyField string `json:"key_field,omitempty" bson:"key_field,omitempty"`
+	Name     string `json:"name,omitempty" bson:"name,omitempty"`
+	Merchant string `json:"merchant,omitempty" bson:"merchant,omitempty"`
+}
+
+func init() {
+	registry.MustRegister(func() ladon.Condition {
+		return new(RateLimit)
+	})
+}
+
+// Validate checks limit, window and key reference
+func (c *RateLimit) Validate() error {
+	if c.Limit <= 0 {
+		return errors.New("limit must be positive")
+	}
+	if c.Window <= 0 {
+		return errors.New("window must be positive")
+	}
+	if c.KeyField != "" {
+		return validateField(c.KeyField)
+	}
+	return nil
+}
+
+// Fulfills checking condition rule
+func (c *RateLimit) Fulfills(value interface{}, r *ladon.Request) bool {
+	if redisClient == nil || c.Limit <= 0 || c.Window <= 0 {
+		return false
+	}
+
+	field := c.KeyField
+	if field == "" {
+		field = FieldSubject
+	}
+	v, ok := resolveField(field, r)
+	if !ok || v == nil || v == "" {
+		return false
+	}
+
+	merchant := c.Merchant
+	if merchant == "" {
+		merchant, _ = value.(string)
+	}
+	if merchant == "" {
+		return false
+	}
+
+	name := c.Name
+	if name == "" {
+		name = r.Action
+	}
+	key := fmt.Sprintf("%s%s:%s:%v", RateLimitKeyPrefix, merchant, name, v)
+
+	allowed, err := slidingWindow.Run(redisClient, []string{key}, c.Window*1000, c.Limit, rand.Int63()).Int64()
+	if err != nil {
+		return false
+	}
+	return allowed == 1
+}
+
+// GetName condition
+func (c *RateLimit) GetName() string {
+	return "RateLimitCondition"
+}
+
+// Description condition
+func (c *RateLimit) Description() string {
+	return "Identity performed the action fewer than limit times within a sliding window"
+}
+
+// SideEffects every fulfilled check counts a hit, evaluation is deferred until other conditions passed
+func (c *RateLimit) SideEffects() bool {
+	return true
+}
@@ -0,0 +1,67 @@
+package conditions
+
+import (
+	"strings"
+
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultScopeSeparator separates a scope from resources nested within it
+	DefaultScopeSeparator = ":"
+)
+
+// ResourceScope match when the request resource is the scope itself or nested within it
+// i.e. scope `room:1` matches `room:1` and `room:1:door` but neither `room:10` nor `room:1-a`
+// Separator defaults to `:`, the scope is compared case sensitively
+type ResourceScope struct {
+	Scope     string `json:"scope" bson:"scope"`
+	Separator string `json:"separator,omitempty" bson:"separator,omitempty"`
+}
+
+func init() {
+	registry.MustRegister(func() ladon.Condition {
+		return new(ResourceScope)
+	})
+}
+
+// Validate refuses scopes which would not match literally
+func (c *ResourceScope) Validate() error {
+	sep := c.separator()
+	switch {
+	case c.Scope == "":
+		return errors.New("scope can not be empty")
+	case strings.ContainsAny(c.Scope, "<>"):
+		return errors.New("scope can not contain patterns")
+	case strings.HasPrefix(c.Scope, sep) || strings.HasSuffix(c.Scope, sep) || strings.Contains(c.Scope, sep+sep):
+		return errors.Errorf("scope can not have empty segments between %q", sep)
+	}
+	return nil
+}
+
+// Fulfills checking condition rule
+func (c *ResourceScope) Fulfills(_ interface{}, r *ladon.Request) bool {
+	if c.Validate() != nil {
+		return false
+	}
+	return r.Resource == c.Scope || strings.HasPrefix(r.Resource, c.Scope+c.separator())
+}
+
+// GetName condition
+func (c *ResourceScope) GetName() string {
+	return "ResourceScopeCondition"
+}
+
+// Description condition
+func (c *ResourceScope) Description() string {
+	return "Request resource is the scope or nested within it"
+}
+
+func (c *ResourceScope) separator() string {
+	if c.Separator == "" {
+		return DefaultScopeSeparator
+	}
+	return c.Separator
+}
@@ -0,0 +1,136 @@
+package conditions
+
+import (
+	"context"
+
+	model "github.com/ndv6/gate/internal/models"
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+// RiskScore match while the risk score of the user stays at or below Threshold
+// the score adds Weights of each action for every event of the user at the merchant within the
+// last Window seconds i.e. `{"login_failed": 10, "device_changed": 25}`, plus NewMerchantWeight
+// when the user has no event at the merchant at all
+// the merchant id is taken from the given value unless Merchant is set, the user id is taken
+// from UserField which defaults to `subject`
+type RiskScore struct {
+	Weights           map[string]float64 `json:"weights" bson:"weights" default:"{}"`
+	NewMerchantWeight float64            `json:"new_merchant_weight" bson:"new_merchant_weight" default:"0"`
+	Window            int64              `json:"window" bson:"window" default:"0"`
+	Threshold         float64            `json:"threshold" bson:"threshold"`
+	Merchant          string             `json:"merchant,omitempty" bson:"merchant,omitempty"`
+	UserField         string             `json:"user_field,omitempty" bson:"user_field,omitempty"`
+}
+
+func init() {
+	registry.MustRegister(func() ladon.Condition {
+		return new(RiskScore)
+	})
+}
+
+// Validate checks weights, window and user reference
+func (c *RiskScore) Validate() error {
+	if len(c.Weights) == 0 && c.NewMerchantWeight == 0 {
+		return errors.New("at least one weight is required")
+	}
+	if c.Window < 0 {
+		return errors.New("window can not be negative")
+	}
+	if c.UserField != "" {
+		return validateField(c.UserField)
+	}
+	return nil
+}
+
+// Fulfills checking condition rule
+func (c *RiskScore) Fulfills(value interface{}, r *ladon.Request) bool {
+	ok, _ := c.Explain(value, r)
+	return ok
+}
+
+// Explain evaluates the condition and reports the computed score along with its signals
+func (c *RiskScore) Explain(value interface{}, r *ladon.Request) (bool, map[string]interface{}) {
+	details := map[string]interface{}{"threshold": c.Threshold}
+	if eventStore == nil {
+		details["error"] = "event store is not configured"
+		return false, details
+	}
+
+	userID, merchantID := eventIdentities(c.Merchant, c.UserField, value, r)
+	if userID == "" || merchantID == "" {
+		details["error"] = "user or merchant is missing"
+		return false, details
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), eventHistoryTimeout)
+	defer cancel()
+
+	score, signals, err := c.score(ctx, userID, merchantID, decisionTime(r))
+	if err != nil {
+		details["error"] = err.Error()
+		return false, details
+	}
+	details["score"] = score
+	details["signals"] = signals
+	return score <= c.Threshold, details
+}
+
+// GetName condition
+func (c *RiskScore) GetName() string {
+	return "RiskScoreCondition"
+}
+
+// Description condition
+func (c *RiskScore) Description() string {
+	return "Weighted score of recent user events stays below a threshold"
+}
+
+func (c *RiskScore) score(ctx context.Context, userID, merchantID string, now int64) (float64, map[string]int64, error) {
+	var (
+		score   float64
+		signals = make(map[string]int64)
+	)
+
+	if len(c.Weights) > 0 {
+		actions := make([]string, 0, len(c.Weights))
+		decreasing := c.NewMerchantWeight < 0
+		for action, weight := range c.Weights {
+			actions = append(actions, action)
+			signals[action] = 0
+			decreasing = decreasing || weight < 0
+		}
+
+		// every weighted action is counted in a single scan, without negative weights the
+		// score only grows so the scan stops as soon as the threshold is exceeded
+		err := scanEvents(ctx, userID, merchantID, actions, nil, c.Window, now, func(e model.Event) bool {
+			signals[e.Action]++
+			score += c.Weights[e.Action]
+			return decreasing || score <= c.Threshold
+		})
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if c.NewMerchantWeight != 0 {
+		merchants, err := eventStore.FindUserMerchants(ctx, userID)
+		if err != nil {
+			return 0, nil, err
+		}
+		known := false
+		for _, m := range merchants {
+			if m == merchantID {
+				known = true
+				break
+			}
+		}
+		if !known {
+			signals["new_merchant"] = 1
+			score += c.NewMerchantWeight
+		}
+	}
+
+	return score, signals, nil
+}
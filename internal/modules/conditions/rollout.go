@@ -0,0 +1,97 @@
+package conditions
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+// rolloutBuckets resolution of the rollout percentage, allows two decimals
+const rolloutBuckets = 10000
+
+// Rollout match a deterministic Percentage (0 - 100) of identities for gradual feature exposure
+// identities are bucketed by a stable hash of Salt and the value of KeyField, `subject` by default,
+// so the same identity consistently falls in or out of the rollout
+// Deny always excludes and Allow always includes listed identities, Deny wins when listed in both
+type Rollout struct {
+	Percentage float64  `json:"percentage" bson:"percentage"`
+	Salt       string   `json:"salt" bson:"salt" default:""`
+	KeyField   string   `json:"key_field,omitempty" bson:"key_field,omitempty"`
+	Allow      []string `json:"allow,omitempty" bson:"allow,omitempty"`
+	Deny       []string `json:"deny,omitempty" bson:"deny,omitempty"`
+}
+
+func init() {
+	registry.MustRegister(func() ladon.Condition {
+		return new(Rollout)
+	})
+}
+
+// Validate checks percentage and key reference
+func (c *Rollout) Validate() error {
+	if c.Percentage < 0 || c.Percentage > 100 {
+		return errors.New("percentage must be between 0 and 100")
+	}
+	if buckets := c.Percentage * rolloutBuckets / 100; math.Abs(buckets-math.Round(buckets)) > 1e-6 {
+		return errors.New("percentage allows at most two decimals")
+	}
+	if c.KeyField != "" {
+		return validateField(c.KeyField)
+	}
+	return nil
+}
+
+// Fulfills checking condition rule
+func (c *Rollout) Fulfills(_ interface{}, r *ladon.Request) bool {
+	field := c.KeyField
+	if field == "" {
+		field = FieldSubject
+	}
+	v, ok := resolveField(field, r)
+	if !ok || v == nil {
+		return false
+	}
+	id := fmt.Sprint(v)
+	if id == "" {
+		return false
+	}
+
+	for _, d := range c.Deny {
+		if d == id {
+			return false
+		}
+	}
+	for _, a := range c.Allow {
+		if a == id {
+			return true
+		}
+	}
+
+	return RolloutBucket(c.Salt, id) < c.threshold()
+}
+
+// threshold number of buckets within the rollout, rounded as percentages such as 0.57 are not exact floats
+func (c *Rollout) threshold() uint64 {
+	return uint64(math.Round(c.Percentage * rolloutBuckets / 100))
+}
+
+// GetName condition
+func (c *Rollout) GetName() string {
+	return "RolloutCondition"
+}
+
+// Description condition
+func (c *Rollout) Description() string {
+	return "Deterministic percentage of identities with allow and deny overrides"
+}
+
+// RolloutBucket returns the stable bucket, between 0 and 9999, an identity falls into for given salt
+func RolloutBucket(salt, id string) uint64 {
+	sum := sha256.Sum256([]byte(salt + ":" + id))
+	return binary.BigEndian.Uint64(sum[:8]) % rolloutBuckets
+}
@@ -0,0 +1,52 @@
+package conditions
+
+import (
+	"github.com/ndv6/gate/internal/modules/semver"
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+)
+
+// SemVer match when given version satisfies the range Constraint
+// i.e. `>=3.4.0 <4`, `^3.4`, `~3.4.1 || >=4.1`, pre-releases only match ranges mentioning them
+type SemVer struct {
+	Constraint string `json:"constraint" bson:"constraint"`
+}
+
+func init() {
+	registry.MustRegister(func() ladon.Condition {
+		return new(SemVer)
+	})
+}
+
+// Validate parses the constraint
+func (c *SemVer) Validate() error {
+	_, err := semver.ParseConstraint(c.Constraint)
+	return err
+}
+
+// Fulfills checking condition rule
+func (c *SemVer) Fulfills(value interface{}, _ *ladon.Request) bool {
+	s, ok := value.(string)
+	if !ok {
+		return false
+	}
+	v, err := semver.Parse(s)
+	if err != nil {
+		return false
+	}
+	constraint, err := semver.ParseConstraint(c.Constraint)
+	if err != nil {
+		return false
+	}
+	return constraint.Check(v)
+}
+
+// GetName condition
+func (c *SemVer) GetName() string {
+	return "SemVerCondition"
+}
+
+// Description condition
+func (c *SemVer) Description() string {
+	return "Given version satisfies a semantic version range"
+}
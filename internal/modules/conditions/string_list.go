@@ -1,20 +1,36 @@
 package conditions
 
-import "github.com/ory/ladon"
+import (
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+)
 
 // StringList match conditions where given value match one of predefined options in case sensitive fashion
+// Field optionally checks a request field i.e. `resource` instead of the given value
 type StringList struct {
 	Options []string `json:"options"`
+	Field   string   `json:"field,omitempty" bson:"field,omitempty"`
 }
 
 func init() {
-	ladon.ConditionFactories[new(StringList).GetName()] = func() ladon.Condition {
+	registry.MustRegister(func() ladon.Condition {
 		return new(StringList)
+	})
+}
+
+// Validate checks the field reference
+func (c *StringList) Validate() error {
+	if c.Field == "" {
+		return nil
 	}
+	return validateField(c.Field)
 }
 
 // Fulfills checking condition rule
-func (c *StringList) Fulfills(value interface{}, _ *ladon.Request) bool {
+func (c *StringList) Fulfills(value interface{}, r *ladon.Request) bool {
+	if c.Field != "" {
+		value, _ = resolveField(c.Field, r)
+	}
 	var rv = make([]string, 0)
 	if s, ok := value.(string); ok {
 		rv = append(rv, s)
@@ -44,3 +60,8 @@ func (c *StringList) Fulfills(value interface{}, _ *ladon.Request) bool {
 func (c *StringList) GetName() string {
 	return "StringListCondition"
 }
+
+// Description condition
+func (c *StringList) Description() string {
+	return "Given value contains every one of the options"
+}
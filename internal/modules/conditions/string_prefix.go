@@ -3,24 +3,38 @@ package conditions
 import (
 	"strings"
 
+	"github.com/ndv6/gate/registry"
 	"github.com/ory/ladon"
 )
 
 // StringPrefix match given value prefixed with pre-defined prefix
 // CaseSensitive an option whether comparison done in case sensitive or not
+// Field optionally checks a request field i.e. `subject` instead of the given value
 type StringPrefix struct {
 	Prefix        string `json:"prefix" bson:"prefix"`
-	CaseSensitive bool   `json:"case_sensitive" bson:"case_sensitive"`
+	CaseSensitive bool   `json:"case_sensitive" bson:"case_sensitive" default:"false"`
+	Field         string `json:"field,omitempty" bson:"field,omitempty"`
 }
 
 func init() {
-	ladon.ConditionFactories[new(StringPrefix).GetName()] = func() ladon.Condition {
+	registry.MustRegister(func() ladon.Condition {
 		return new(StringPrefix)
+	})
+}
+
+// Validate checks the field reference
+func (c *StringPrefix) Validate() error {
+	if c.Field == "" {
+		return nil
 	}
+	return validateField(c.Field)
 }
 
 // Fulfills checking condition rule
-func (c *StringPrefix) Fulfills(value interface{}, _ *ladon.Request) bool {
+func (c *StringPrefix) Fulfills(value interface{}, r *ladon.Request) bool {
+	if c.Field != "" {
+		value, _ = resolveField(c.Field, r)
+	}
 	s, ok := value.(string)
 	if !ok {
 		return false
@@ -35,3 +49,8 @@ func (c *StringPrefix) Fulfills(value interface{}, _ *ladon.Request) bool {
 func (c *StringPrefix) GetName() string {
 	return "StringPrefixCondition"
 }
+
+// Description condition
+func (c *StringPrefix) Description() string {
+	return "Given value starts with a prefix"
+}
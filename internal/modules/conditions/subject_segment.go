@@ -0,0 +1,85 @@
+package conditions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+// SubjectSegment match when a segment of the request subject equals given value
+// i.e. subject `users:42` with Index 1 matches context `owner_id` 42
+// Separator defaults to `:`, a negative Index counts from the last segment
+// Field optionally compares against another request field instead of the given value
+type SubjectSegment struct {
+	Separator string `json:"separator" bson:"separator" default:":"`
+	Index     int    `json:"index" bson:"index" default:"0"`
+	Field     string `json:"field,omitempty" bson:"field,omitempty"`
+}
+
+func init() {
+	registry.MustRegister(func() ladon.Condition {
+		return new(SubjectSegment)
+	})
+}
+
+// Validate checks the field reference
+func (c *SubjectSegment) Validate() error {
+	if c.Field == "" {
+		return nil
+	}
+	if c.Field == FieldSubject {
+		return errors.New("subject can not be compared against itself")
+	}
+	return validateField(c.Field)
+}
+
+// Fulfills checking condition rule
+func (c *SubjectSegment) Fulfills(value interface{}, r *ladon.Request) bool {
+	if c.Field != "" {
+		var ok bool
+		if value, ok = resolveField(c.Field, r); !ok {
+			return false
+		}
+	}
+	if value == nil {
+		return false
+	}
+
+	sep := c.Separator
+	if sep == "" {
+		sep = ":"
+	}
+	segments := strings.Split(r.Subject, sep)
+	i := c.Index
+	if i < 0 {
+		i += len(segments)
+	}
+	if i < 0 || i >= len(segments) || segments[i] == "" {
+		return false
+	}
+
+	switch v := value.(type) {
+	case string:
+		return segments[i] == v
+	case float64:
+		// JSON numbers decode as float64, %v would print large ids in exponent notation
+		return segments[i] == strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return segments[i] == strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return segments[i] == fmt.Sprint(value)
+}
+
+// GetName condition
+func (c *SubjectSegment) GetName() string {
+	return "SubjectSegmentCondition"
+}
+
+// Description condition
+func (c *SubjectSegment) Description() string {
+	return "Segment of the request subject equals given value"
+}
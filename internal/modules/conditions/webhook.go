@@ -0,0 +1,236 @@
+package conditions
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+const (
+	// WebhookSignatureHeader carries `sha256=<hex hmac>` of `<timestamp>.<body>`
+	WebhookSignatureHeader = "X-Gate-Signature"
+
+	// WebhookTimestampHeader carries the unix time the request was signed at
+	WebhookTimestampHeader = "X-Gate-Timestamp"
+
+	webhookDefaultTimeout = 1000  // milliseconds
+	webhookMaxTimeout     = 30000 // milliseconds
+	webhookCacheSize      = 10000
+	webhookMaxResponse    = 64 << 10
+)
+
+var (
+	webhookClient = &http.Client{}
+	webhookKeys   = map[string][]byte{}
+	webhookCache  = &responseCache{entries: make(map[string]cachedResponse)}
+)
+
+// UseWebhookKeys sets the HMAC keys webhook conditions sign their requests with, referenced by KeyID
+func UseWebhookKeys(keys map[string][]byte) {
+	webhookKeys = keys
+}
+
+// Webhook match when an external HTTP endpoint approves the request
+// the endpoint receives a POST of `{"request": .., "value": .., "parameters": ..}` and answers
+// either `true`/`false` or `{"allow": true}`; Timeout is in milliseconds and CacheTTL in seconds,
+// FailOpen decides the outcome when the endpoint can not be reached or answers unexpectedly
+// requests are signed with the key registered under KeyID, see WebhookSignatureHeader
+type Webhook struct {
+	URL        string                 `json:"url" bson:"url"`
+	Parameters map[string]interface{} `json:"parameters,omitempty" bson:"parameters,omitempty"`
+	Timeout    int64                  `json:"timeout" bson:"timeout" default:"1000"`
+	CacheTTL   int64                  `json:"cache_ttl" bson:"cache_ttl" default:"0"`
+	FailOpen   bool                   `json:"fail_open" bson:"fail_open" default:"false"`
+	KeyID      string                 `json:"key_id,omitempty" bson:"key_id,omitempty"`
+}
+
+func init() {
+	registry.MustRegister(func() ladon.Condition {
+		return new(Webhook)
+	})
+}
+
+// Validate checks endpoint, timing and signing key
+func (c *Webhook) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Errorf("invalid webhook url %q", c.URL)
+	}
+	if c.Timeout < 0 || c.Timeout > webhookMaxTimeout {
+		return errors.Errorf("timeout must be between 0 and %d milliseconds", webhookMaxTimeout)
+	}
+	if c.CacheTTL < 0 {
+		return errors.New("cache ttl can not be negative")
+	}
+	if c.KeyID != "" {
+		if _, ok := webhookKeys[c.KeyID]; !ok {
+			return errors.Errorf("unknown signing key %q", c.KeyID)
+		}
+	}
+	return nil
+}
+
+// Fulfills checking condition rule
+func (c *Webhook) Fulfills(value interface{}, r *ladon.Request) bool {
+	body, err := json.Marshal(map[string]interface{}{
+		"request":    r,
+		"value":      value,
+		"parameters": c.Parameters,
+	})
+	if err != nil {
+		return c.FailOpen
+	}
+
+	sum := sha256.Sum256(append([]byte(c.URL+"\n"), body...))
+	key := hex.EncodeToString(sum[:])
+	if c.CacheTTL > 0 {
+		if allowed, ok := webhookCache.get(key); ok {
+			return allowed
+		}
+	}
+
+	allowed, err := c.call(body)
+	if err != nil {
+		return c.FailOpen
+	}
+	if c.CacheTTL > 0 {
+		webhookCache.set(key, allowed, time.Duration(c.CacheTTL)*time.Second)
+	}
+	return allowed
+}
+
+// GetName condition
+func (c *Webhook) GetName() string {
+	return "WebhookCondition"
+}
+
+// Description condition
+func (c *Webhook) Description() string {
+	return "External HTTP endpoint approves the request"
+}
+
+// SideEffects every check may call the endpoint, evaluation is deferred until other conditions passed
+func (c *Webhook) SideEffects() bool {
+	return true
+}
+
+func (c *Webhook) call(body []byte) (bool, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = webhookDefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	if c.KeyID != "" {
+		secret, ok := webhookKeys[c.KeyID]
+		if !ok {
+			return false, errors.Errorf("unknown signing key %q", c.KeyID)
+		}
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, ts)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(secret, ts, body))
+	}
+
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		return false, errors.Wrap(err, "failed calling webhook")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, webhookMaxResponse))
+		return false, errors.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+
+	var out interface{}
+	if err := json.NewDecoder(io.LimitReader(res.Body, webhookMaxResponse)).Decode(&out); err != nil {
+		return false, errors.Wrap(err, "failed decoding webhook response")
+	}
+	switch v := out.(type) {
+	case bool:
+		return v, nil
+	case map[string]interface{}:
+		if allowed, ok := v["allow"].(bool); ok {
+			return allowed, nil
+		}
+	}
+	return false, errors.New("webhook response is not a boolean")
+}
+
+// SignWebhook returns the hex encoded HMAC-SHA256 of `<timestamp>.<body>`, receivers use it to verify requests
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type cachedResponse struct {
+	allowed bool
+	expires time.Time
+}
+
+// responseCache bounded in-memory cache of webhook answers
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+}
+
+func (rc *responseCache) get(key string) (bool, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	e, ok := rc.entries[key]
+	if !ok {
+		return false, false
+	}
+	if time.Now().After(e.expires) {
+		delete(rc.entries, key)
+		return false, false
+	}
+	return e.allowed, true
+}
+
+func (rc *responseCache) set(key string, allowed bool, ttl time.Duration) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if len(rc.entries) >= webhookCacheSize {
+		now := time.Now()
+		for k, e := range rc.entries {
+			if now.After(e.expires) {
+				delete(rc.entries, k)
+			}
+		}
+		// still full, make room by dropping arbitrary entries
+		for k := range rc.entries {
+			if len(rc.entries) < webhookCacheSize {
+				break
+			}
+			delete(rc.entries, k)
+		}
+	}
+	rc.entries[key] = cachedResponse{allowed: allowed, expires: time.Now().Add(ttl)}
+}
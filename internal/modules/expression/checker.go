@@ -0,0 +1,312 @@
+package expression
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// Type of an expression value known at compile time
+type Type string
+
+const (
+	TypeDyn    Type = "dyn"
+	TypeNull   Type = "null"
+	TypeBool   Type = "bool"
+	TypeNumber Type = "number"
+	TypeString Type = "string"
+	TypeList   Type = "list"
+	TypeMap    Type = "map"
+)
+
+// Env declares variables available to an expression along with their types
+type Env map[string]Type
+
+type checker struct {
+	env     Env
+	types   map[node]Type
+	regexps map[node]*regexp.Regexp
+}
+
+func (c *checker) check(n node) (t Type, err error) {
+	t, err = c.infer(n)
+	if err == nil {
+		c.types[n] = t
+	}
+	return
+}
+
+func (c *checker) infer(n node) (Type, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		return typeOf(n.value), nil
+
+	case *identNode:
+		t, ok := c.env[n.name]
+		if !ok {
+			return "", errors.Errorf("undeclared reference to %q at %d", n.name, n.pos)
+		}
+		return t, nil
+
+	case *listNode:
+		for _, item := range n.items {
+			if _, err := c.check(item); err != nil {
+				return "", err
+			}
+		}
+		return TypeList, nil
+
+	case *memberNode:
+		t, err := c.check(n.target)
+		if err != nil {
+			return "", err
+		}
+		if !oneOf(t, TypeMap) {
+			return "", errors.Errorf("type %s has no field %q at %d", t, n.field, n.pos)
+		}
+		return TypeDyn, nil
+
+	case *indexNode:
+		t, err := c.check(n.target)
+		if err != nil {
+			return "", err
+		}
+		i, err := c.check(n.index)
+		if err != nil {
+			return "", err
+		}
+		switch t {
+		case TypeList:
+			if !oneOf(i, TypeNumber) {
+				return "", errors.Errorf("list index must be number, got %s at %d", i, n.pos)
+			}
+		case TypeMap:
+			if !oneOf(i, TypeString) {
+				return "", errors.Errorf("map key must be string, got %s at %d", i, n.pos)
+			}
+		case TypeDyn:
+		default:
+			return "", errors.Errorf("type %s does not support indexing at %d", t, n.pos)
+		}
+		return TypeDyn, nil
+
+	case *callNode:
+		return c.inferCall(n)
+
+	case *unaryNode:
+		t, err := c.check(n.operand)
+		if err != nil {
+			return "", err
+		}
+		if n.op == "!" {
+			if !oneOf(t, TypeBool) {
+				return "", errors.Errorf("operator ! requires bool, got %s at %d", t, n.pos)
+			}
+			return TypeBool, nil
+		}
+		if !oneOf(t, TypeNumber) {
+			return "", errors.Errorf("operator - requires number, got %s at %d", t, n.pos)
+		}
+		return TypeNumber, nil
+
+	case *binaryNode:
+		return c.inferBinary(n)
+
+	case *conditionalNode:
+		ct, err := c.check(n.cond)
+		if err != nil {
+			return "", err
+		}
+		if !oneOf(ct, TypeBool) {
+			return "", errors.Errorf("condition must be bool, got %s at %d", ct, n.pos)
+		}
+		a, err := c.check(n.then)
+		if err != nil {
+			return "", err
+		}
+		b, err := c.check(n.otherwise)
+		if err != nil {
+			return "", err
+		}
+		if a == b {
+			return a, nil
+		}
+		return TypeDyn, nil
+	}
+
+	return "", errors.Errorf("unsupported expression at %d", n.position())
+}
+
+func (c *checker) inferBinary(n *binaryNode) (Type, error) {
+	l, err := c.check(n.left)
+	if err != nil {
+		return "", err
+	}
+	r, err := c.check(n.right)
+	if err != nil {
+		return "", err
+	}
+
+	switch n.op {
+	case "&&", "||":
+		if !oneOf(l, TypeBool) || !oneOf(r, TypeBool) {
+			return "", errors.Errorf("operator %s requires bool operands, got %s and %s at %d", n.op, l, r, n.pos)
+		}
+		return TypeBool, nil
+
+	case "+":
+		switch {
+		case l == TypeDyn || r == TypeDyn:
+			if l != TypeDyn && !oneOf(l, TypeNumber, TypeString, TypeList) ||
+				r != TypeDyn && !oneOf(r, TypeNumber, TypeString, TypeList) {
+				return "", errors.Errorf("operator + does not support %s and %s at %d", l, r, n.pos)
+			}
+			if l != TypeDyn {
+				return l, nil
+			}
+			return r, nil
+		case l == r && oneOf(l, TypeNumber, TypeString, TypeList):
+			return l, nil
+		}
+		return "", errors.Errorf("operator + does not support %s and %s at %d", l, r, n.pos)
+
+	case "-", "*", "/", "%":
+		if !oneOf(l, TypeNumber) || !oneOf(r, TypeNumber) {
+			return "", errors.Errorf("operator %s requires number operands, got %s and %s at %d", n.op, l, r, n.pos)
+		}
+		return TypeNumber, nil
+
+	case "<", "<=", ">", ">=":
+		if l != TypeDyn && r != TypeDyn && (l != r || !oneOf(l, TypeNumber, TypeString)) {
+			return "", errors.Errorf("operator %s can not compare %s and %s at %d", n.op, l, r, n.pos)
+		}
+		if !oneOf(l, TypeNumber, TypeString) || !oneOf(r, TypeNumber, TypeString) {
+			return "", errors.Errorf("operator %s can not compare %s and %s at %d", n.op, l, r, n.pos)
+		}
+		return TypeBool, nil
+
+	case "==", "!=":
+		if l != r && l != TypeDyn && r != TypeDyn && l != TypeNull && r != TypeNull {
+			return "", errors.Errorf("operator %s can not compare %s and %s at %d", n.op, l, r, n.pos)
+		}
+		return TypeBool, nil
+
+	case "in":
+		if !oneOf(r, TypeList, TypeMap) {
+			return "", errors.Errorf("operator in requires list or map, got %s at %d", r, n.pos)
+		}
+		return TypeBool, nil
+	}
+
+	return "", errors.Errorf("unknown operator %s at %d", n.op, n.pos)
+}
+
+func (c *checker) inferCall(n *callNode) (Type, error) {
+	if n.target == nil {
+		switch n.name {
+		case "size":
+			if len(n.args) != 1 {
+				return "", errors.Errorf("size expects 1 argument at %d", n.pos)
+			}
+			t, err := c.check(n.args[0])
+			if err != nil {
+				return "", err
+			}
+			if !oneOf(t, TypeString, TypeList, TypeMap) {
+				return "", errors.Errorf("size does not support %s at %d", t, n.pos)
+			}
+			return TypeNumber, nil
+
+		case "has":
+			if len(n.args) != 1 {
+				return "", errors.Errorf("has expects 1 argument at %d", n.pos)
+			}
+			switch n.args[0].(type) {
+			case *memberNode, *indexNode:
+			default:
+				return "", errors.Errorf("has expects a field selection at %d", n.pos)
+			}
+			if _, err := c.check(n.args[0]); err != nil {
+				return "", err
+			}
+			return TypeBool, nil
+		}
+		return "", errors.Errorf("unknown function %q at %d", n.name, n.pos)
+	}
+
+	t, err := c.check(n.target)
+	if err != nil {
+		return "", err
+	}
+	args := make([]Type, len(n.args))
+	for i, a := range n.args {
+		if args[i], err = c.check(a); err != nil {
+			return "", err
+		}
+	}
+
+	switch n.name {
+	case "startsWith", "endsWith", "contains", "matches":
+		if !oneOf(t, TypeString) {
+			return "", errors.Errorf("%s is not defined on %s at %d", n.name, t, n.pos)
+		}
+		if len(args) != 1 || !oneOf(args[0], TypeString) {
+			return "", errors.Errorf("%s expects 1 string argument at %d", n.name, n.pos)
+		}
+		if n.name == "matches" {
+			if lit, ok := n.args[0].(*literalNode); ok {
+				re, err := regexp.Compile(lit.value.(string))
+				if err != nil {
+					return "", errors.Wrapf(err, "invalid pattern at %d", n.pos)
+				}
+				c.regexps[n] = re
+			}
+		}
+		return TypeBool, nil
+
+	case "lower", "upper":
+		if !oneOf(t, TypeString) || len(args) != 0 {
+			return "", errors.Errorf("%s is not defined on %s at %d", n.name, t, n.pos)
+		}
+		return TypeString, nil
+
+	case "size":
+		if !oneOf(t, TypeString, TypeList, TypeMap) || len(args) != 0 {
+			return "", errors.Errorf("size is not defined on %s at %d", t, n.pos)
+		}
+		return TypeNumber, nil
+	}
+
+	return "", errors.Errorf("unknown method %q at %d", n.name, n.pos)
+}
+
+// oneOf reports whether t is dynamic or one of the accepted types
+func oneOf(t Type, accepted ...Type) bool {
+	if t == TypeDyn {
+		return true
+	}
+	for _, a := range accepted {
+		if t == a {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(v interface{}) Type {
+	switch v.(type) {
+	case nil:
+		return TypeNull
+	case bool:
+		return TypeBool
+	case float64:
+		return TypeNumber
+	case string:
+		return TypeString
+	case []interface{}:
+		return TypeList
+	case map[string]interface{}:
+		return TypeMap
+	}
+	return TypeDyn
+}
@@ -0,0 +1,136 @@
+package expression
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+// operators ordered longest first so the lexer is greedy
+var operators = []string{
+	"&&", "||", "==", "!=", "<=", ">=",
+	"!", "<", ">", "+", "-", "*", "/", "%",
+	"(", ")", "[", "]", ",", ".", "?", ":",
+}
+
+func tokenize(src string) ([]token, error) {
+	var (
+		tokens = make([]token, 0)
+		i      = 0
+	)
+
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && isIdentPart(src[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[start:i], pos: start})
+
+		case isDigit(c):
+			start := i
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			n, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, errors.Errorf("invalid number %q at %d", src[start:i], start)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[start:i], value: n, pos: start})
+
+		case c == '"' || c == '\'':
+			start := i
+			s, n, err := readString(src[i:])
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid string at %d", start)
+			}
+			i += n
+			tokens = append(tokens, token{kind: tokenString, text: src[start:i], value: s, pos: start})
+
+		default:
+			var matched bool
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, errors.Errorf("unexpected character %q at %d", c, i)
+			}
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
+
+// readString reads a quoted string literal and returns its value and the number of consumed bytes
+func readString(src string) (string, int, error) {
+	var (
+		quote = src[0]
+		b     strings.Builder
+	)
+	for i := 1; i < len(src); i++ {
+		switch c := src[i]; c {
+		case quote:
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(src) {
+				return "", 0, errors.New("unterminated escape sequence")
+			}
+			i++
+			switch e := src[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '\\', '\'', '"':
+				b.WriteByte(e)
+			default:
+				return "", 0, errors.Errorf("unknown escape sequence \\%c", e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errors.New("unterminated string")
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
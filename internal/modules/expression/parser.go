@@ -0,0 +1,383 @@
+package expression
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	maxDepth = 64
+	maxNodes = 512
+)
+
+type node interface {
+	position() int
+}
+
+type (
+	literalNode struct {
+		pos   int
+		value interface{}
+	}
+
+	identNode struct {
+		pos  int
+		name string
+	}
+
+	listNode struct {
+		pos   int
+		items []node
+	}
+
+	memberNode struct {
+		pos    int
+		target node
+		field  string
+	}
+
+	indexNode struct {
+		pos    int
+		target node
+		index  node
+	}
+
+	callNode struct {
+		pos    int
+		target node // nil for global functions
+		name   string
+		args   []node
+	}
+
+	unaryNode struct {
+		pos     int
+		op      string
+		operand node
+	}
+
+	binaryNode struct {
+		pos   int
+		op    string
+		left  node
+		right node
+	}
+
+	conditionalNode struct {
+		pos       int
+		cond      node
+		then      node
+		otherwise node
+	}
+)
+
+func (n *literalNode) position() int     { return n.pos }
+func (n *identNode) position() int       { return n.pos }
+func (n *listNode) position() int        { return n.pos }
+func (n *memberNode) position() int      { return n.pos }
+func (n *indexNode) position() int       { return n.pos }
+func (n *callNode) position() int        { return n.pos }
+func (n *unaryNode) position() int       { return n.pos }
+func (n *binaryNode) position() int      { return n.pos }
+func (n *conditionalNode) position() int { return n.pos }
+
+type parser struct {
+	tokens []token
+	cur    int
+	depth  int
+	nodes  int
+}
+
+func parse(src string) (node, int, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	p := &parser{tokens: tokens}
+	n, err := p.parseExpression()
+	if err != nil {
+		return nil, 0, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, 0, errors.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return n, p.nodes, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.cur]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.cur]
+	if t.kind != tokenEOF {
+		p.cur++
+	}
+	return t
+}
+
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokenOperator && t.text == op {
+		p.cur++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return errors.Errorf("expected %q at %d", op, t.pos)
+	}
+	return nil
+}
+
+func (p *parser) enter() error {
+	p.depth++
+	p.nodes++
+	if p.depth > maxDepth {
+		return errors.Errorf("expression nested deeper than %d levels", maxDepth)
+	}
+	if p.nodes > maxNodes {
+		return errors.Errorf("expression exceeds %d nodes", maxNodes)
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) parseExpression() (node, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	pos := p.peek().pos
+	if !p.accept("?") {
+		return cond, nil
+	}
+	then, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return &conditionalNode{pos: pos, cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *parser) parseAnd() (node, error) {
+	return p.parseBinary(p.parseRelation, "&&")
+}
+
+func (p *parser) parseRelation() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	op := ""
+	switch {
+	case t.kind == tokenOperator && (t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">=" || t.text == "==" || t.text == "!="):
+		op = t.text
+	case t.kind == tokenIdent && t.text == "in":
+		op = t.text
+	default:
+		return left, nil
+	}
+	p.next()
+	p.nodes++
+
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{pos: t.pos, op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+func (p *parser) parseBinary(operand func() (node, error), ops ...string) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		var op string
+		for _, o := range ops {
+			if t.kind == tokenOperator && t.text == o {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		p.next()
+		p.nodes++
+
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{pos: t.pos, op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	if t.kind == tokenOperator && (t.text == "!" || t.text == "-") {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{pos: t.pos, op: t.text, operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		switch {
+		case p.accept("."):
+			p.nodes++
+			f := p.next()
+			if f.kind != tokenIdent {
+				return nil, errors.Errorf("expected field name at %d", f.pos)
+			}
+			if p.accept("(") {
+				args, err := p.parseArguments(")")
+				if err != nil {
+					return nil, err
+				}
+				n = &callNode{pos: f.pos, target: n, name: f.text, args: args}
+				continue
+			}
+			n = &memberNode{pos: f.pos, target: n, field: f.text}
+
+		case p.accept("["):
+			p.nodes++
+			idx, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			n = &indexNode{pos: t.pos, target: n, index: idx}
+
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	p.nodes++
+	if p.nodes > maxNodes {
+		return nil, errors.Errorf("expression exceeds %d nodes", maxNodes)
+	}
+
+	t := p.next()
+	switch t.kind {
+	case tokenNumber, tokenString:
+		return &literalNode{pos: t.pos, value: t.value}, nil
+
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{pos: t.pos, value: true}, nil
+		case "false":
+			return &literalNode{pos: t.pos, value: false}, nil
+		case "null":
+			return &literalNode{pos: t.pos, value: nil}, nil
+		}
+		if p.accept("(") {
+			args, err := p.parseArguments(")")
+			if err != nil {
+				return nil, err
+			}
+			return &callNode{pos: t.pos, name: t.text, args: args}, nil
+		}
+		return &identNode{pos: t.pos, name: t.text}, nil
+
+	case tokenOperator:
+		switch t.text {
+		case "(":
+			n, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+		case "[":
+			items, err := p.parseArguments("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{pos: t.pos, items: items}, nil
+		}
+	}
+
+	if t.kind == tokenEOF {
+		return nil, errors.New("unexpected end of expression")
+	}
+	return nil, errors.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *parser) parseArguments(closing string) ([]node, error) {
+	args := make([]node, 0)
+	if p.accept(closing) {
+		return args, nil
+	}
+	for {
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.accept(closing) {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,562 @@
+package expression
+
+import (
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxSourceLength limits the size of an expression source
+	MaxSourceLength = 4096
+
+	// DefaultCostLimit is the evaluation budget used when no limit is given
+	DefaultCostLimit = 1000
+)
+
+var (
+	// ErrCostExceeded is returned when evaluation runs out of budget
+	ErrCostExceeded = errors.New("expression evaluation cost exceeded")
+)
+
+// Program is a parsed and type-checked expression ready for evaluation
+type Program struct {
+	source  string
+	root    node
+	types   map[node]Type
+	regexps map[node]*regexp.Regexp
+}
+
+// Compile parses src and type-checks it against env
+func Compile(src string, env Env) (*Program, error) {
+	if len(src) > MaxSourceLength {
+		return nil, errors.Errorf("expression longer than %d characters", MaxSourceLength)
+	}
+	if strings.TrimSpace(src) == "" {
+		return nil, errors.New("expression is empty")
+	}
+
+	root, _, err := parse(src)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed parsing expression")
+	}
+
+	c := &checker{env: env, types: make(map[node]Type), regexps: make(map[node]*regexp.Regexp)}
+	if _, err := c.check(root); err != nil {
+		return nil, errors.Wrap(err, "failed checking expression")
+	}
+
+	return &Program{source: src, root: root, types: c.types, regexps: c.regexps}, nil
+}
+
+// Source returns the expression the program was compiled from
+func (p *Program) Source() string {
+	return p.source
+}
+
+// ResultType returns the statically known type of the expression result
+func (p *Program) ResultType() Type {
+	return p.types[p.root]
+}
+
+// Eval evaluates the program with the given variables, it fails once the accumulated cost exceeds limit
+func (p *Program) Eval(vars map[string]interface{}, limit int) (interface{}, error) {
+	if limit <= 0 {
+		limit = DefaultCostLimit
+	}
+	e := &evaluator{program: p, vars: vars, budget: limit}
+	return e.eval(p.root)
+}
+
+type evaluator struct {
+	program *Program
+	vars    map[string]interface{}
+	budget  int
+}
+
+func (e *evaluator) spend(n int) error {
+	e.budget -= n
+	if e.budget < 0 {
+		return ErrCostExceeded
+	}
+	return nil
+}
+
+func (e *evaluator) eval(n node) (interface{}, error) {
+	if err := e.spend(1); err != nil {
+		return nil, err
+	}
+
+	switch n := n.(type) {
+	case *literalNode:
+		return n.value, nil
+
+	case *identNode:
+		return e.normalize(e.vars[n.name])
+
+	case *listNode:
+		out := make([]interface{}, len(n.items))
+		for i, item := range n.items {
+			v, err := e.eval(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+
+	case *memberNode:
+		t, err := e.eval(n.target)
+		if err != nil {
+			return nil, err
+		}
+		return e.field(t, n.field)
+
+	case *indexNode:
+		t, err := e.eval(n.target)
+		if err != nil {
+			return nil, err
+		}
+		i, err := e.eval(n.index)
+		if err != nil {
+			return nil, err
+		}
+		return e.index(t, i)
+
+	case *callNode:
+		return e.call(n)
+
+	case *unaryNode:
+		v, err := e.eval(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "!" {
+			b, ok := v.(bool)
+			if !ok {
+				return nil, errors.Errorf("operator ! requires bool, got %T", v)
+			}
+			return !b, nil
+		}
+		f, ok := v.(float64)
+		if !ok {
+			return nil, errors.Errorf("operator - requires number, got %T", v)
+		}
+		return -f, nil
+
+	case *binaryNode:
+		return e.binary(n)
+
+	case *conditionalNode:
+		c, err := e.eval(n.cond)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := c.(bool)
+		if !ok {
+			return nil, errors.Errorf("condition must be bool, got %T", c)
+		}
+		if b {
+			return e.eval(n.then)
+		}
+		return e.eval(n.otherwise)
+	}
+
+	return nil, errors.Errorf("unsupported expression at %d", n.position())
+}
+
+func (e *evaluator) binary(n *binaryNode) (interface{}, error) {
+	l, err := e.eval(n.left)
+	if err != nil {
+		return nil, err
+	}
+
+	// logical operators short-circuit before evaluating the right side
+	if n.op == "&&" || n.op == "||" {
+		lb, ok := l.(bool)
+		if !ok {
+			return nil, errors.Errorf("operator %s requires bool, got %T", n.op, l)
+		}
+		if (n.op == "&&" && !lb) || (n.op == "||" && lb) {
+			return lb, nil
+		}
+		r, err := e.eval(n.right)
+		if err != nil {
+			return nil, err
+		}
+		rb, ok := r.(bool)
+		if !ok {
+			return nil, errors.Errorf("operator %s requires bool, got %T", n.op, r)
+		}
+		return rb, nil
+	}
+
+	r, err := e.eval(n.right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return e.equals(l, r)
+	case "!=":
+		eq, err := e.equals(l, r)
+		if err != nil {
+			return nil, err
+		}
+		return !eq, nil
+	case "in":
+		return e.contains(r, l)
+	case "<", "<=", ">", ">=":
+		return compare(n.op, l, r)
+	case "+":
+		return e.add(l, r)
+	}
+
+	lf, lok := l.(float64)
+	rf, rok := r.(float64)
+	if !lok || !rok {
+		return nil, errors.Errorf("operator %s requires numbers, got %T and %T", n.op, l, r)
+	}
+	switch n.op {
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return lf / rf, nil
+	case "%":
+		if rf == 0 {
+			return nil, errors.New("modulus by zero")
+		}
+		return math.Mod(lf, rf), nil
+	}
+
+	return nil, errors.Errorf("unknown operator %s", n.op)
+}
+
+func (e *evaluator) add(l, r interface{}) (interface{}, error) {
+	switch lv := l.(type) {
+	case float64:
+		if rv, ok := r.(float64); ok {
+			return lv + rv, nil
+		}
+	case string:
+		if rv, ok := r.(string); ok {
+			if err := e.spend((len(lv) + len(rv)) / 16); err != nil {
+				return nil, err
+			}
+			return lv + rv, nil
+		}
+	case []interface{}:
+		if rv, ok := r.([]interface{}); ok {
+			if err := e.spend(len(lv) + len(rv)); err != nil {
+				return nil, err
+			}
+			out := make([]interface{}, 0, len(lv)+len(rv))
+			return append(append(out, lv...), rv...), nil
+		}
+	}
+	return nil, errors.Errorf("operator + does not support %T and %T", l, r)
+}
+
+func (e *evaluator) equals(l, r interface{}) (bool, error) {
+	switch lv := l.(type) {
+	case []interface{}:
+		rv, ok := r.([]interface{})
+		if !ok || len(lv) != len(rv) {
+			return false, nil
+		}
+		for i := range lv {
+			a, err := e.normalize(lv[i])
+			if err != nil {
+				return false, err
+			}
+			b, err := e.normalize(rv[i])
+			if err != nil {
+				return false, err
+			}
+			if eq, err := e.equals(a, b); err != nil || !eq {
+				return false, err
+			}
+		}
+		return true, nil
+	case map[string]interface{}:
+		if err := e.spend(len(lv)); err != nil {
+			return false, err
+		}
+		return reflect.DeepEqual(l, r), nil
+	}
+	return l == r, nil
+}
+
+func (e *evaluator) contains(haystack, needle interface{}) (bool, error) {
+	switch h := haystack.(type) {
+	case []interface{}:
+		if err := e.spend(len(h)); err != nil {
+			return false, err
+		}
+		for _, item := range h {
+			v, err := e.normalize(item)
+			if err != nil {
+				return false, err
+			}
+			if eq, err := e.equals(needle, v); err != nil {
+				return false, err
+			} else if eq {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]interface{}:
+		k, ok := needle.(string)
+		if !ok {
+			return false, errors.Errorf("map key must be string, got %T", needle)
+		}
+		_, found := h[k]
+		return found, nil
+	}
+	return false, errors.Errorf("operator in requires list or map, got %T", haystack)
+}
+
+func (e *evaluator) field(target interface{}, name string) (interface{}, error) {
+	m, ok := target.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("type %T has no field %q", target, name)
+	}
+	v, found := m[name]
+	if !found {
+		return nil, errors.Errorf("no such key %q", name)
+	}
+	return e.normalize(v)
+}
+
+func (e *evaluator) index(target, idx interface{}) (interface{}, error) {
+	switch t := target.(type) {
+	case map[string]interface{}:
+		k, ok := idx.(string)
+		if !ok {
+			return nil, errors.Errorf("map key must be string, got %T", idx)
+		}
+		return e.field(t, k)
+	case []interface{}:
+		f, ok := idx.(float64)
+		if !ok || f != math.Trunc(f) {
+			return nil, errors.Errorf("list index must be an integer, got %v", idx)
+		}
+		i := int(f)
+		if i < 0 || i >= len(t) {
+			return nil, errors.Errorf("index %d out of range", i)
+		}
+		return e.normalize(t[i])
+	}
+	return nil, errors.Errorf("type %T does not support indexing", target)
+}
+
+func (e *evaluator) call(n *callNode) (interface{}, error) {
+	if n.target == nil {
+		switch n.name {
+		case "has":
+			var err error
+			switch a := n.args[0].(type) {
+			case *memberNode:
+				var t interface{}
+				if t, err = e.eval(a.target); err == nil {
+					m, ok := t.(map[string]interface{})
+					if !ok {
+						return false, nil
+					}
+					_, found := m[a.field]
+					return found, nil
+				}
+			case *indexNode:
+				if _, err = e.eval(a); err == nil {
+					return true, nil
+				}
+				if err == ErrCostExceeded {
+					return nil, err
+				}
+				return false, nil
+			}
+			return nil, err
+		case "size":
+			v, err := e.eval(n.args[0])
+			if err != nil {
+				return nil, err
+			}
+			return size(v)
+		}
+		return nil, errors.Errorf("unknown function %q", n.name)
+	}
+
+	t, err := e.eval(n.target)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, len(n.args))
+	for i, a := range n.args {
+		if args[i], err = e.eval(a); err != nil {
+			return nil, err
+		}
+	}
+
+	if n.name == "size" {
+		return size(t)
+	}
+
+	s, ok := t.(string)
+	if !ok {
+		return nil, errors.Errorf("%s is not defined on %T", n.name, t)
+	}
+	if err := e.spend(len(s) / 16); err != nil {
+		return nil, err
+	}
+
+	switch n.name {
+	case "lower":
+		return strings.ToLower(s), nil
+	case "upper":
+		return strings.ToUpper(s), nil
+	}
+
+	a, ok := args[0].(string)
+	if !ok {
+		return nil, errors.Errorf("%s expects string argument, got %T", n.name, args[0])
+	}
+	switch n.name {
+	case "startsWith":
+		return strings.HasPrefix(s, a), nil
+	case "endsWith":
+		return strings.HasSuffix(s, a), nil
+	case "contains":
+		return strings.Contains(s, a), nil
+	case "matches":
+		re, found := e.program.regexps[n]
+		if !found {
+			if err := e.spend(len(a)); err != nil {
+				return nil, err
+			}
+			if re, err = regexp.Compile(a); err != nil {
+				return nil, errors.Wrap(err, "invalid pattern")
+			}
+		}
+		if err := e.spend(len(s)); err != nil {
+			return nil, err
+		}
+		return re.MatchString(s), nil
+	}
+
+	return nil, errors.Errorf("unknown method %q", n.name)
+}
+
+// normalize converts arbitrary Go values into the expression value domain
+func (e *evaluator) normalize(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case nil, bool, float64, string, []interface{}, map[string]interface{}:
+		return v, nil
+	case int:
+		return float64(t), nil
+	case int32:
+		return float64(t), nil
+	case int64:
+		return float64(t), nil
+	case uint:
+		return float64(t), nil
+	case uint32:
+		return float64(t), nil
+	case uint64:
+		return float64(t), nil
+	case float32:
+		return float64(t), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if err := e.spend(rv.Len()); err != nil {
+			return nil, err
+		}
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			out[i] = rv.Index(i).Interface()
+		}
+		return out, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, errors.Errorf("unsupported map key type %s", rv.Type().Key())
+		}
+		if err := e.spend(rv.Len()); err != nil {
+			return nil, err
+		}
+		out := make(map[string]interface{}, rv.Len())
+		for _, k := range rv.MapKeys() {
+			out[k.String()] = rv.MapIndex(k).Interface()
+		}
+		return out, nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+
+	return nil, errors.Errorf("unsupported value type %T", v)
+}
+
+func compare(op string, l, r interface{}) (bool, error) {
+	var c int
+	switch lv := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		if !ok {
+			return false, errors.Errorf("can not compare %T and %T", l, r)
+		}
+		switch {
+		case lv < rv:
+			c = -1
+		case lv > rv:
+			c = 1
+		}
+	case string:
+		rv, ok := r.(string)
+		if !ok {
+			return false, errors.Errorf("can not compare %T and %T", l, r)
+		}
+		c = strings.Compare(lv, rv)
+	default:
+		return false, errors.Errorf("can not compare %T and %T", l, r)
+	}
+
+	switch op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	}
+	return c >= 0, nil
+}
+
+func size(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		return float64(len([]rune(t))), nil
+	case []interface{}:
+		return float64(len(t)), nil
+	case map[string]interface{}:
+		return float64(len(t)), nil
+	}
+	return nil, errors.Errorf("size is not defined on %T", v)
+}
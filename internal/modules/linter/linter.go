@@ -0,0 +1,358 @@
+package linter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/compiler"
+	"github.com/pkg/errors"
+)
+
+const (
+	// SeverityError findings make the policy misbehave and should be fixed
+	SeverityError = "error"
+
+	// SeverityWarning findings are most likely mistakes
+	SeverityWarning = "warning"
+
+	// SeverityInfo findings are worth a review
+	SeverityInfo = "info"
+)
+
+const (
+	// KindDuplicate policy is identical to another one
+	KindDuplicate = "duplicate"
+
+	// KindShadowed allow policy is fully covered by an unconditional deny policy, it never grants access
+	KindShadowed = "shadowed"
+
+	// KindRedundant allow policy is fully covered by another unconditional allow policy
+	KindRedundant = "redundant"
+
+	// KindOverlap allow and deny policies apply to some common requests
+	KindOverlap = "overlap"
+
+	// KindWildcard policy matches every subject, resource or action
+	KindWildcard = "wildcard"
+
+	// KindInvalidPattern policy contains a pattern which can not be compiled, requests never match it
+	KindInvalidPattern = "invalid_pattern"
+
+	// KindUnknownCondition policy references a condition type which is not registered
+	KindUnknownCondition = "unknown_condition"
+
+	pageSize = 100
+)
+
+// Finding reported by the linter, Related lists ids of other policies involved
+type Finding struct {
+	Policy   string   `json:"policy"`
+	Kind     string   `json:"kind"`
+	Severity string   `json:"severity"`
+	Message  string   `json:"message"`
+	Related  []string `json:"related,omitempty"`
+}
+
+// HasErrors reports whether any of findings is an error
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// JSONLister is implemented by managers listing stored policies as JSON documents without decoding
+// their conditions, see policies.MongoPolicyManager.GetAllJSON
+type JSONLister interface {
+	GetAllJSON(limit, offset int64) ([]json.RawMessage, error)
+}
+
+// LintManager analyzes every policy stored by the manager, policies of a JSONLister are read as
+// documents so conditions of unknown types are reported instead of failing decoding
+func LintManager(m ladon.Manager) ([]Finding, error) {
+	if jl, ok := m.(JSONLister); ok {
+		var docs []json.RawMessage
+		for offset := int64(0); ; offset += pageSize {
+			ps, err := jl.GetAllJSON(pageSize, offset)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed retrieving policies")
+			}
+			docs = append(docs, ps...)
+			if len(ps) < pageSize {
+				break
+			}
+		}
+		if docs == nil {
+			docs = []json.RawMessage{}
+		}
+		data, err := json.Marshal(docs)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return LintJSON(data)
+	}
+
+	var all ladon.Policies
+	for offset := int64(0); ; offset += pageSize {
+		ps, err := m.GetAll(pageSize, offset)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed retrieving policies")
+		}
+		all = append(all, ps...)
+		if len(ps) < pageSize {
+			break
+		}
+	}
+	return Lint(all), nil
+}
+
+// LintJSON analyzes a JSON list of policies, conditions of unknown types are reported instead of failing decoding
+func LintJSON(data []byte) ([]Finding, error) {
+	var docs []map[string]interface{}
+	if err := json.Unmarshal(data, &docs); err != nil {
+		return nil, errors.Wrap(err, "failed decoding policies")
+	}
+
+	var findings []Finding
+	for _, doc := range docs {
+		cs, ok := doc["conditions"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := doc["id"].(string)
+		for key, c := range cs {
+			m, _ := c.(map[string]interface{})
+			name, _ := m["type"].(string)
+			if registry.Exists(name) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Policy:   id,
+				Kind:     KindUnknownCondition,
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("condition %s references unknown type %q", key, name),
+			})
+			delete(cs, key)
+		}
+	}
+
+	cleaned, err := json.Marshal(docs)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var dps []*ladon.DefaultPolicy
+	if err := json.Unmarshal(cleaned, &dps); err != nil {
+		return nil, errors.Wrap(err, "failed decoding policies")
+	}
+
+	ps := make(ladon.Policies, 0, len(dps))
+	for _, p := range dps {
+		ps = append(ps, p)
+	}
+	return sortFindings(append(findings, Lint(ps)...)), nil
+}
+
+// Lint analyzes a set of policies
+func Lint(ps ladon.Policies) []Finding {
+	var findings []Finding
+	for _, p := range ps {
+		findings = append(findings, lintPolicy(p)...)
+	}
+
+	for i, a := range ps {
+		for j, b := range ps {
+			if i == j {
+				continue
+			}
+			findings = append(findings, lintPair(a, b, i < j)...)
+		}
+	}
+	return sortFindings(findings)
+}
+
+func lintPolicy(p ladon.Policy) []Finding {
+	var findings []Finding
+	fields := []struct {
+		name   string
+		values []string
+	}{
+		{"subjects", p.GetSubjects()},
+		{"resources", p.GetResources()},
+		{"actions", p.GetActions()},
+	}
+	for _, field := range fields {
+		for _, v := range field.values {
+			if _, err := compiler.CompileRegex(v, p.GetStartDelimiter(), p.GetEndDelimiter()); err != nil {
+				findings = append(findings, Finding{
+					Policy:   p.GetID(),
+					Kind:     KindInvalidPattern,
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("%s pattern %q can not be compiled: %s", field.name, v, err),
+				})
+				continue
+			}
+			if isWildcard(p, v) {
+				findings = append(findings, Finding{
+					Policy:   p.GetID(),
+					Kind:     KindWildcard,
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("%s pattern %q matches everything", field.name, v),
+				})
+			}
+		}
+	}
+
+	for key, c := range p.GetConditions() {
+		if !registry.Exists(c.GetName()) {
+			findings = append(findings, Finding{
+				Policy:   p.GetID(),
+				Kind:     KindUnknownCondition,
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("condition %s references unknown type %q", key, c.GetName()),
+			})
+		}
+	}
+	return findings
+}
+
+// lintPair reports findings of a against b, symmetric findings are only reported once when first is set
+func lintPair(a, b ladon.Policy, first bool) []Finding {
+	if a.AllowAccess() == b.AllowAccess() && covers(a, b) && covers(b, a) && sameConditions(a, b) {
+		if !first {
+			return nil
+		}
+		return []Finding{{
+			Policy:   a.GetID(),
+			Kind:     KindDuplicate,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("policy is identical to %s", b.GetID()),
+			Related:  []string{b.GetID()},
+		}}
+	}
+
+	if !a.AllowAccess() {
+		return nil
+	}
+
+	unconditional := len(b.GetConditions()) == 0
+	switch {
+	case !b.AllowAccess() && unconditional && covers(b, a):
+		return []Finding{{
+			Policy:   a.GetID(),
+			Kind:     KindShadowed,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("allow is fully covered by deny %s and never grants access", b.GetID()),
+			Related:  []string{b.GetID()},
+		}}
+	case b.AllowAccess() && unconditional && covers(b, a):
+		return []Finding{{
+			Policy:   a.GetID(),
+			Kind:     KindRedundant,
+			Severity: SeverityInfo,
+			Message:  fmt.Sprintf("allow is fully covered by allow %s", b.GetID()),
+			Related:  []string{b.GetID()},
+		}}
+	case !b.AllowAccess() && overlaps(a, b):
+		return []Finding{{
+			Policy:   a.GetID(),
+			Kind:     KindOverlap,
+			Severity: SeverityInfo,
+			Message:  fmt.Sprintf("allow partially overlaps deny %s", b.GetID()),
+			Related:  []string{b.GetID()},
+		}}
+	}
+	return nil
+}
+
+// covers reports whether every subject, resource and action of inner is matched by outer
+func covers(outer, inner ladon.Policy) bool {
+	return coversAll(outer, outer.GetSubjects(), inner.GetSubjects()) &&
+		coversAll(outer, outer.GetResources(), inner.GetResources()) &&
+		coversAll(outer, outer.GetActions(), inner.GetActions())
+}
+
+func coversAll(p ladon.Policy, haystack, needles []string) bool {
+	for _, n := range needles {
+		if !matches(p, haystack, n) {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps reports whether subjects, resources and actions of both policies have something in common
+func overlaps(a, b ladon.Policy) bool {
+	return overlapsAny(a, b, a.GetSubjects(), b.GetSubjects()) &&
+		overlapsAny(a, b, a.GetResources(), b.GetResources()) &&
+		overlapsAny(a, b, a.GetActions(), b.GetActions())
+}
+
+func overlapsAny(a, b ladon.Policy, as, bs []string) bool {
+	for _, n := range bs {
+		if matches(a, as, n) {
+			return true
+		}
+	}
+	for _, n := range as {
+		if matches(b, bs, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether needle is one of haystack or matched by one of its patterns
+// the matcher only takes needles as values, a pattern it doesn't match may still be the same pattern
+func matches(p ladon.Policy, haystack []string, needle string) bool {
+	for _, h := range haystack {
+		if h == needle {
+			return true
+		}
+	}
+	ok, err := ladon.DefaultMatcher.Matches(p, haystack, needle)
+	return err == nil && ok
+}
+
+func sameConditions(a, b ladon.Policy) bool {
+	if len(a.GetConditions()) == 0 || len(b.GetConditions()) == 0 {
+		return len(a.GetConditions()) == len(b.GetConditions())
+	}
+	ca, err := json.Marshal(a.GetConditions())
+	if err != nil {
+		return false
+	}
+	cb, err := json.Marshal(b.GetConditions())
+	if err != nil {
+		return false
+	}
+	return string(ca) == string(cb)
+}
+
+// isWildcard reports whether the pattern is a single expression matching anything i.e. `<.*>`
+func isWildcard(p ladon.Policy, v string) bool {
+	start, end := string(p.GetStartDelimiter()), string(p.GetEndDelimiter())
+	if !strings.HasPrefix(v, start) || !strings.HasSuffix(v, end) || strings.Count(v, start) != 1 {
+		return false
+	}
+	switch strings.TrimSuffix(strings.TrimPrefix(v, start), end) {
+	case ".*", ".+", "(.*)", "(.+)":
+		return true
+	}
+	return false
+}
+
+func sortFindings(findings []Finding) []Finding {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Policy != findings[j].Policy {
+			return findings[i].Policy < findings[j].Policy
+		}
+		return findings[i].Kind < findings[j].Kind
+	})
+	return findings
+}
@@ -3,11 +3,17 @@ package policies
 import (
 	"encoding/json"
 
+	"github.com/ndv6/gate/registry"
 	"github.com/ory/ladon"
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
+// Validator is implemented by conditions which can verify their options before a policy is stored
+type Validator interface {
+	Validate() error
+}
+
 // Conditions ladon
 type Conditions ladon.Conditions
 
@@ -36,36 +42,27 @@ func (cs Conditions) UnmarshalBSON(data []byte) error {
 	}
 
 	var jcs map[string]jsonCondition
-	var dc ladon.Condition
 
 	if err := bson.Unmarshal(data, &jcs); err != nil {
 		return errors.WithStack(err)
 	}
 
 	for k, jc := range jcs {
-		var found bool
-		for name, c := range ladon.ConditionFactories {
-			if name == jc.Type {
-				found = true
-				dc = c()
-
-				if len(jc.Options) == 0 {
-					cs[k] = dc
-					break
-				}
-
-				if err := bson.Unmarshal(jc.Options, dc); err != nil {
-					return errors.WithStack(err)
-				}
-
-				cs[k] = dc
-				break
-			}
+		dc, err := registry.Lookup(jc.Type)
+		if err != nil {
+			return err
 		}
 
-		if !found {
-			return errors.Errorf("Could not find condition type %s", jc.Type)
+		if len(jc.Options) == 0 {
+			cs[k] = dc
+			continue
 		}
+
+		if err := bson.Unmarshal(jc.Options, dc); err != nil {
+			return errors.WithStack(err)
+		}
+
+		cs[k] = dc
 	}
 
 	return nil
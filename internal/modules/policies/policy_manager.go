@@ -2,8 +2,10 @@ package policies
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/ory/ladon"
 	"github.com/pkg/errors"
@@ -31,7 +33,8 @@ var (
 
 // MongoPolicyManager is ...
 type MongoPolicyManager struct {
-	db *mongo.Collection
+	db        *mongo.Collection
+	separator string
 }
 
 // NewMongoPolicyManager is ...
@@ -39,9 +42,24 @@ func NewMongoPolicyManager(merchant string, db *mongo.Database) *MongoPolicyMana
 	return &MongoPolicyManager{db: db.Collection(fmt.Sprintf("%s%s", merchant, PolicyTableSuffix))}
 }
 
+// UseResourceSeparator enables hierarchical resources, request candidates include policies of
+// every ancestor of the requested resource i.e. `room:1` and `room` for `room:1:door` with `:`
+// wardens using the manager default to the same separator
+func (pm *MongoPolicyManager) UseResourceSeparator(separator string) {
+	pm.separator = separator
+}
+
+// ResourceSeparator returns the separator set by UseResourceSeparator
+func (pm *MongoPolicyManager) ResourceSeparator() string {
+	return pm.separator
+}
+
 // Create policy
 func (pm *MongoPolicyManager) Create(policy ladon.Policy) error {
 	pp := policy.(*DefaultPolicy)
+	if err := validateConditions(pp.GetConditions()); err != nil {
+		return err
+	}
 	if pp.ID == "" {
 		pp.ID = primitive.NewObjectID().Hex()
 	}
@@ -57,6 +75,9 @@ func (pm *MongoPolicyManager) Update(policy ladon.Policy) error {
 	if policy.GetID() == "" {
 		return errors.Wrap(ErrPolicyInvalidParameter, "update request requires id attribute")
 	}
+	if err := validateConditions(policy.GetConditions()); err != nil {
+		return err
+	}
 
 	updated := bson.D{{"$set", policy}}
 	if _, err := pm.db.UpdateOne(context.TODO(), bson.D{{"_id", policy.GetID()}}, updated); err != nil {
@@ -97,9 +118,14 @@ func (pm *MongoPolicyManager) Delete(id string) error {
 	return nil
 }
 
+// pageOptions pages policies sorted by id so consecutive pages neither skip nor repeat policies
+func pageOptions(limit, offset int64) *options.FindOptions {
+	return options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit).SetSkip(offset)
+}
+
 // GetAll policies stored
 func (pm *MongoPolicyManager) GetAll(limit, offset int64) (ladon.Policies, error) {
-	c, err := pm.db.Find(context.TODO(), bson.M{}, options.Find().SetLimit(limit).SetSkip(offset))
+	c, err := pm.db.Find(context.TODO(), bson.M{}, pageOptions(limit, offset))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed retrieving all policies")
 	}
@@ -107,34 +133,59 @@ func (pm *MongoPolicyManager) GetAll(limit, offset int64) (ladon.Policies, error
 	return pm.policiesListFromCursor(c)
 }
 
+// GetAllJSON returns stored policies as JSON documents, conditions are kept as stored so policies
+// using condition types which are not registered can still be inspected
+func (pm *MongoPolicyManager) GetAllJSON(limit, offset int64) ([]json.RawMessage, error) {
+	c, err := pm.db.Find(context.TODO(), bson.M{}, pageOptions(limit, offset))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed retrieving all policies")
+	}
+	defer c.Close(context.TODO())
+
+	docs := make([]json.RawMessage, 0)
+	for c.Next(context.TODO()) {
+		doc, err := policyJSON(c.Current)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	if err := c.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed retrieving all policies")
+	}
+	return docs, nil
+}
+
 // FindRequestCandidates is ...
+// like FindPoliciesForSubject and FindPoliciesForResource policies whose patterns may match are returned as well,
+// the warden matches candidates against the request
 func (pm *MongoPolicyManager) FindRequestCandidates(r *ladon.Request) (ladon.Policies, error) {
 	opt := options.Find().SetLimit(0)
 	qp := bson.A{}
 	if r.Subject != "" {
-		qp = append(qp, bson.D{{
-			"subjects", bson.D{
-				{"$regex", primitive.Regex{
-					Pattern: fmt.Sprintf("^%s", regexp.QuoteMeta(r.Subject)),
-					Options: "i",
-				}},
-			},
-		}})
+		qp = append(qp, prefixOrPattern("subjects", r.Subject))
 	}
 
 	if r.Resource != "" {
-		qp = append(qp, bson.D{{
-			"resources", bson.D{
-				{"$regex", primitive.Regex{
-					Pattern: fmt.Sprintf("^%s", regexp.QuoteMeta(r.Resource)),
-					Options: "i",
-				}},
-			},
-		}})
+		rq := bson.A{bson.M{"resources": bson.M{"$regex": primitive.Regex{Pattern: "<"}}}}
+		for _, resource := range Ancestors(r.Resource, pm.separator) {
+			rq = append(rq, bson.D{{
+				"resources", bson.D{
+					{"$regex", primitive.Regex{
+						Pattern: fmt.Sprintf("^%s", regexp.QuoteMeta(resource)),
+						Options: "i",
+					}},
+				},
+			}})
+		}
+		qp = append(qp, bson.D{{"$or", rq}})
 	}
 
 	if r.Action != "" {
-		qp = append(qp, bson.D{{"actions", r.Action}})
+		qp = append(qp, bson.M{"$or": bson.A{
+			bson.M{"actions": r.Action},
+			bson.M{"actions": bson.M{"$regex": primitive.Regex{Pattern: "<"}}},
+		}})
 	}
 
 	query := bson.D{{"$and", qp}}
@@ -143,43 +194,127 @@ func (pm *MongoPolicyManager) FindRequestCandidates(r *ladon.Request) (ladon.Pol
 		return nil, errors.Wrap(err, "failed retrieving policies by request")
 	}
 
-	return pm.policiesListFromCursor(c)
+	ps, err := pm.policiesListFromCursor(c)
+	if err != nil || r.Subject == "" {
+		return ps, err
+	}
+	return filterPatterns(ps, r.Subject, func(p ladon.Policy) []string { return p.GetSubjects() })
 }
 
 // FindPoliciesForSubject is to search policies stored for specified subject
+// policies listing a subject starting with it are returned along with those whose subject patterns match it
 func (pm *MongoPolicyManager) FindPoliciesForSubject(subject string) (ladon.Policies, error) {
-	query := bson.M{"subjects": bson.M{"$regex": primitive.Regex{
-		Pattern: fmt.Sprintf("^%s", regexp.QuoteMeta(subject)),
-		Options: "i",
-	}}}
-
 	opt := options.Find().SetLimit(0)
-	c, err := pm.db.Find(context.TODO(), query, opt)
+	c, err := pm.db.Find(context.TODO(), prefixOrPattern("subjects", subject), opt)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed retrieving policies by subject")
 	}
 
-	return pm.policiesListFromCursor(c)
+	ps, err := pm.policiesListFromCursor(c)
+	if err != nil {
+		return nil, err
+	}
+	return filterPatterns(ps, subject, func(p ladon.Policy) []string { return p.GetSubjects() })
 }
 
-// FindPoliciesForResource is ...
+// FindPoliciesForResource is to search policies stored for specified resource
+// policies listing a resource starting with it are returned along with those whose resource patterns match it
 func (pm *MongoPolicyManager) FindPoliciesForResource(resource string) (ladon.Policies, error) {
 	opt := options.Find().SetLimit(0)
-	query := bson.D{
-		{"resources", bson.D{
-			{"$regex", primitive.Regex{
-				Pattern: fmt.Sprintf("^%s", regexp.QuoteMeta(resource)),
-				Options: "i",
-			}},
-		}},
+	c, err := pm.db.Find(context.TODO(), prefixOrPattern("resources", resource), opt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed retrieving policies by resource")
 	}
 
-	c, err := pm.db.Find(context.TODO(), query, opt)
+	ps, err := pm.policiesListFromCursor(c)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed retrieving policies by resource")
+		return nil, err
 	}
+	return filterPatterns(ps, resource, func(p ladon.Policy) []string { return p.GetResources() })
+}
 
-	return pm.policiesListFromCursor(c)
+// Ancestors returns the resource followed by its ancestors, nearest first, by cutting it at the separator
+// i.e. `room:1:door:3`, `room:1:door`, `room:1` and `room`; without separator only the resource is returned
+func Ancestors(resource, separator string) []string {
+	resources := []string{resource}
+	if separator == "" {
+		return resources
+	}
+	for i := strings.LastIndex(resource, separator); i > 0; i = strings.LastIndex(resource, separator) {
+		resource = resource[:i]
+		resources = append(resources, resource)
+	}
+	return resources
+}
+
+// policyJSON converts a stored policy into the JSON ladon policies are decoded from
+func policyJSON(raw bson.Raw) (json.RawMessage, error) {
+	ext, err := bson.MarshalExtJSON(raw, false, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed converting policy")
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(ext, &doc); err != nil {
+		return nil, errors.Wrap(err, "failed converting policy")
+	}
+
+	// ids are stored as _id and meta as binary, which JSON policies do not expect
+	doc["id"] = doc["_id"]
+	delete(doc, "_id")
+	delete(doc, "meta")
+
+	data, err := json.Marshal(doc)
+	return data, errors.WithStack(err)
+}
+
+// prefixOrPattern selects documents whose field lists a value starting with given one or any pattern
+func prefixOrPattern(field, value string) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{"$regex": primitive.Regex{
+			Pattern: fmt.Sprintf("^%s", regexp.QuoteMeta(value)),
+			Options: "i",
+		}}},
+		bson.M{field: bson.M{"$regex": primitive.Regex{Pattern: "<"}}},
+	}}
+}
+
+// filterPatterns drops policies selected by prefixOrPattern only for having a pattern which does not match
+func filterPatterns(ps ladon.Policies, value string, field func(ladon.Policy) []string) (ladon.Policies, error) {
+	var out ladon.Policies
+	for _, p := range ps {
+		keep := false
+		for _, v := range field(p) {
+			if strings.HasPrefix(strings.ToLower(v), strings.ToLower(value)) {
+				keep = true
+				break
+			}
+		}
+		if !keep {
+			ok, err := ladon.DefaultMatcher.Matches(p, field(p), value)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			keep = ok
+		}
+		if keep {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// validateConditions checks options of every condition able to validate itself
+func validateConditions(cs ladon.Conditions) error {
+	for k, c := range cs {
+		v, ok := c.(Validator)
+		if !ok {
+			continue
+		}
+		if err := v.Validate(); err != nil {
+			return errors.Wrapf(ErrPolicyInvalidParameter, "condition %s (%s) is invalid: %s", k, c.GetName(), err)
+		}
+	}
+	return nil
 }
 
 func (pm *MongoPolicyManager) policiesListFromCursor(c *mongo.Cursor) (ladon.Policies, error) {
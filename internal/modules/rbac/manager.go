@@ -0,0 +1,191 @@
+package rbac
+
+import (
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrRBACInvalidParameter is ...
+	ErrRBACInvalidParameter = errors.New("role or binding contains invalid parameter")
+
+	// ErrRoleNotFound is ...
+	ErrRoleNotFound = errors.New("requested role could not be found")
+
+	// ErrRoleExists is ...
+	ErrRoleExists = errors.New("role already exists")
+
+	// ErrRoleInUse is returned when deleting a role which is still bound to subjects
+	ErrRoleInUse = errors.New("role is still bound to subjects")
+
+	// ErrBindingNotFound is ...
+	ErrBindingNotFound = errors.New("requested role binding could not be found")
+)
+
+// Store persists roles and bindings of a merchant
+type Store interface {
+	GetRole(name string) (*Role, error)
+	SaveRole(r *Role) error
+	DeleteRole(name string) error
+	GetBinding(id string) (*RoleBinding, error)
+	SaveBinding(b *RoleBinding) error
+	DeleteBinding(id string) error
+	FindBindings(role string) ([]*RoleBinding, error)
+}
+
+// Manager maintains roles and bindings along with the policies compiled from them
+type Manager struct {
+	Store    Store
+	Policies ladon.Manager
+}
+
+// NewManager is ...
+func NewManager(s Store, p ladon.Manager) *Manager {
+	return &Manager{Store: s, Policies: p}
+}
+
+// NewMongoManager stores roles, bindings and compiled policies of the merchant in mongo
+func NewMongoManager(merchant string, db *mongo.Database) *Manager {
+	return NewManager(NewMongoStore(merchant, db), policies.NewMongoPolicyManager(merchant, db))
+}
+
+// CreateRole is ...
+func (m *Manager) CreateRole(r *Role) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if _, err := m.Store.GetRole(r.Name); err == nil {
+		return errors.Wrapf(ErrRoleExists, "role %s", r.Name)
+	} else if errors.Cause(err) != ErrRoleNotFound {
+		return err
+	}
+	return m.Store.SaveRole(r)
+}
+
+// UpdateRole replaces the role and recompiles policies of every binding
+func (m *Manager) UpdateRole(r *Role) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if _, err := m.Store.GetRole(r.Name); err != nil {
+		return err
+	}
+	if err := m.Store.SaveRole(r); err != nil {
+		return err
+	}
+
+	bindings, err := m.Store.FindBindings(r.Name)
+	if err != nil {
+		return err
+	}
+	for _, b := range bindings {
+		if err := m.sync(r, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteRole deletes a role which is not bound anymore
+func (m *Manager) DeleteRole(name string) error {
+	bindings, err := m.Store.FindBindings(name)
+	if err != nil {
+		return err
+	}
+	if len(bindings) > 0 {
+		return errors.Wrapf(ErrRoleInUse, "role %s has %d bindings", name, len(bindings))
+	}
+	return m.Store.DeleteRole(name)
+}
+
+// Bind grants the role to subjects of the binding and compiles its policies
+func (m *Manager) Bind(b *RoleBinding) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	r, err := m.Store.GetRole(b.Role)
+	if err != nil {
+		return err
+	}
+	if b.ID == "" {
+		b.ID = primitive.NewObjectID().Hex()
+	}
+	return m.sync(r, b)
+}
+
+// Unbind removes the binding and its compiled policies
+func (m *Manager) Unbind(id string) error {
+	b, err := m.Store.GetBinding(id)
+	if err != nil {
+		return err
+	}
+	if err := m.deletePolicies(b.Policies); err != nil {
+		return err
+	}
+	return m.Store.DeleteBinding(id)
+}
+
+// sync replaces policies previously compiled from the stored binding with the current version of the role
+// the binding is saved listing both old and new policies before any of them is touched, a sync failing
+// halfway leaves no policy behind unrecorded and is completed by binding again or updating the role
+func (m *Manager) sync(r *Role, b *RoleBinding) error {
+	compiled, err := Compile(r, b)
+	if err != nil {
+		return err
+	}
+
+	var previous []string
+	stored, err := m.Store.GetBinding(b.ID)
+	if err == nil {
+		previous = stored.Policies
+	} else if errors.Cause(err) != ErrBindingNotFound {
+		return err
+	}
+
+	ids := make([]string, 0, len(compiled))
+	for _, p := range compiled {
+		ids = append(ids, p.ID)
+	}
+
+	b.Policies = union(previous, ids)
+	if err := m.Store.SaveBinding(b); err != nil {
+		return err
+	}
+	if err := m.deletePolicies(b.Policies); err != nil {
+		return err
+	}
+	for _, p := range compiled {
+		if err := m.Policies.Create(p); err != nil {
+			return errors.Wrapf(err, "failed compiling binding %s", b.ID)
+		}
+	}
+
+	b.Policies = ids
+	return m.Store.SaveBinding(b)
+}
+
+func (m *Manager) deletePolicies(ids []string) error {
+	for _, id := range ids {
+		if err := m.Policies.Delete(id); err != nil && errors.Cause(err) != policies.ErrPolicyNotFound {
+			return errors.Wrapf(err, "failed deleting managed policy %s", id)
+		}
+	}
+	return nil
+}
+
+func union(a, b []string) []string {
+	var (
+		out  = make([]string, 0, len(a)+len(b))
+		seen = make(map[string]bool)
+	)
+	for _, s := range append(append([]string{}, a...), b...) {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
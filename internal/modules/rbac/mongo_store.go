@@ -0,0 +1,113 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// RoleTableSuffix ...
+	RoleTableSuffix = "_roles"
+
+	// RoleBindingTableSuffix ...
+	RoleBindingTableSuffix = "_role_bindings"
+)
+
+// MongoStore is ...
+type MongoStore struct {
+	roles    *mongo.Collection
+	bindings *mongo.Collection
+}
+
+// NewMongoStore is ...
+func NewMongoStore(merchant string, db *mongo.Database) *MongoStore {
+	return &MongoStore{
+		roles:    db.Collection(fmt.Sprintf("%s%s", merchant, RoleTableSuffix)),
+		bindings: db.Collection(fmt.Sprintf("%s%s", merchant, RoleBindingTableSuffix)),
+	}
+}
+
+// GetRole by name
+func (s *MongoStore) GetRole(name string) (*Role, error) {
+	var r = new(Role)
+	if err := s.roles.FindOne(context.TODO(), bson.M{"_id": name}).Decode(r); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.Wrapf(ErrRoleNotFound, "role %s does not exists", name)
+		}
+		return nil, errors.Wrapf(err, "failed retrieving role %s", name)
+	}
+	return r, nil
+}
+
+// SaveRole creates or replaces the role
+func (s *MongoStore) SaveRole(r *Role) error {
+	opt := options.Replace().SetUpsert(true)
+	if _, err := s.roles.ReplaceOne(context.TODO(), bson.M{"_id": r.Name}, r, opt); err != nil {
+		return errors.Wrapf(err, "failed saving role %s", r.Name)
+	}
+	return nil
+}
+
+// DeleteRole is ...
+func (s *MongoStore) DeleteRole(name string) error {
+	r, err := s.roles.DeleteOne(context.TODO(), bson.M{"_id": name})
+	if err != nil {
+		return errors.Wrap(err, "failed deleting role")
+	}
+	if r.DeletedCount == 0 {
+		return errors.Wrapf(ErrRoleNotFound, "role %s does not exists", name)
+	}
+	return nil
+}
+
+// GetBinding by id
+func (s *MongoStore) GetBinding(id string) (*RoleBinding, error) {
+	var b = new(RoleBinding)
+	if err := s.bindings.FindOne(context.TODO(), bson.M{"_id": id}).Decode(b); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.Wrapf(ErrBindingNotFound, "binding %s does not exists", id)
+		}
+		return nil, errors.Wrapf(err, "failed retrieving binding %s", id)
+	}
+	return b, nil
+}
+
+// SaveBinding creates or replaces the binding
+func (s *MongoStore) SaveBinding(b *RoleBinding) error {
+	opt := options.Replace().SetUpsert(true)
+	if _, err := s.bindings.ReplaceOne(context.TODO(), bson.M{"_id": b.ID}, b, opt); err != nil {
+		return errors.Wrapf(err, "failed saving binding %s", b.ID)
+	}
+	return nil
+}
+
+// DeleteBinding is ...
+func (s *MongoStore) DeleteBinding(id string) error {
+	r, err := s.bindings.DeleteOne(context.TODO(), bson.M{"_id": id})
+	if err != nil {
+		return errors.Wrap(err, "failed deleting binding")
+	}
+	if r.DeletedCount == 0 {
+		return errors.Wrapf(ErrBindingNotFound, "binding %s does not exists", id)
+	}
+	return nil
+}
+
+// FindBindings of a role
+func (s *MongoStore) FindBindings(role string) ([]*RoleBinding, error) {
+	c, err := s.bindings.Find(context.TODO(), bson.M{"role": role})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed retrieving bindings of role %s", role)
+	}
+
+	var bindings []*RoleBinding
+	if err := c.All(context.TODO(), &bindings); err != nil {
+		return nil, errors.Wrap(err, "failed decoding bindings")
+	}
+	return bindings, nil
+}
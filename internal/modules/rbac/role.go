@@ -0,0 +1,115 @@
+package rbac
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ManagedBy marks meta of policies compiled from role bindings
+	ManagedBy = "rbac"
+
+	// PolicyIDPrefix prefixes ids of policies compiled from role bindings
+	PolicyIDPrefix = "rbac:"
+
+	// ScopeConditionKey is the condition restricting compiled policies to the binding scope
+	ScopeConditionKey = "rbac_scope"
+)
+
+// Permission allows Actions on Resources, both support ladon regular expressions i.e. `room:<.*>`
+type Permission struct {
+	Resources []string `json:"resources" bson:"resources"`
+	Actions   []string `json:"actions" bson:"actions"`
+}
+
+// Role named set of permissions
+type Role struct {
+	Name        string       `json:"name" bson:"_id"`
+	Description string       `json:"description" bson:"description"`
+	Permissions []Permission `json:"permissions" bson:"permissions"`
+}
+
+// Validate checks role name and permissions
+func (r *Role) Validate() error {
+	if r.Name == "" {
+		return errors.Wrap(ErrRBACInvalidParameter, "role requires a name")
+	}
+	if len(r.Permissions) == 0 {
+		return errors.Wrapf(ErrRBACInvalidParameter, "role %s requires at least one permission", r.Name)
+	}
+	for i, p := range r.Permissions {
+		if len(p.Resources) == 0 || len(p.Actions) == 0 {
+			return errors.Wrapf(ErrRBACInvalidParameter, "permission #%d of role %s requires resources and actions", i, r.Name)
+		}
+	}
+	return nil
+}
+
+// RoleBinding grants a role to subjects, Scope optionally restricts the role to the scope resource and
+// resources nested within it i.e. `room:1` and `room:1:door` but not `room:10`, nesting is told by
+// ScopeSeparator which defaults to `:`
+// Policies holds ids of the policies compiled from the binding
+type RoleBinding struct {
+	ID             string   `json:"id" bson:"_id"`
+	Role           string   `json:"role" bson:"role"`
+	Subjects       []string `json:"subjects" bson:"subjects"`
+	Scope          string   `json:"scope,omitempty" bson:"scope,omitempty"`
+	ScopeSeparator string   `json:"scope_separator,omitempty" bson:"scope_separator,omitempty"`
+	Policies       []string `json:"policies" bson:"policies"`
+}
+
+// Validate checks role and subjects of the binding
+func (b *RoleBinding) Validate() error {
+	if b.Role == "" {
+		return errors.Wrap(ErrRBACInvalidParameter, "binding requires a role")
+	}
+	if len(b.Subjects) == 0 {
+		return errors.Wrapf(ErrRBACInvalidParameter, "binding of role %s requires at least one subject", b.Role)
+	}
+	if b.Scope != "" {
+		if err := b.scope().Validate(); err != nil {
+			return errors.Wrapf(ErrRBACInvalidParameter, "scope %q of binding is invalid: %s", b.Scope, err)
+		}
+	}
+	return nil
+}
+
+func (b *RoleBinding) scope() *conditions.ResourceScope {
+	return &conditions.ResourceScope{Scope: b.Scope, Separator: b.ScopeSeparator}
+}
+
+// Compile turns the binding of the role into one allow policy per permission
+func Compile(role *Role, b *RoleBinding) ([]*policies.DefaultPolicy, error) {
+	meta, err := json.Marshal(map[string]string{
+		"managed_by": ManagedBy,
+		"role":       role.Name,
+		"binding":    b.ID,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	compiled := make([]*policies.DefaultPolicy, 0, len(role.Permissions))
+	for i, p := range role.Permissions {
+		pol := &policies.DefaultPolicy{
+			ID:          fmt.Sprintf("%s%s:%d", PolicyIDPrefix, b.ID, i),
+			Description: fmt.Sprintf("Managed by binding %s of role %s", b.ID, role.Name),
+			Subjects:    b.Subjects,
+			Effect:      ladon.AllowAccess,
+			Resources:   p.Resources,
+			Actions:     p.Actions,
+			Conditions:  policies.Conditions{},
+			Meta:        meta,
+		}
+		if b.Scope != "" {
+			pol.Conditions[ScopeConditionKey] = b.scope()
+		}
+		compiled = append(compiled, pol)
+	}
+	return compiled, nil
+}
@@ -0,0 +1,233 @@
+package semver
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type comparator struct {
+	op      string
+	version Version
+}
+
+func (c comparator) check(v *Version) bool {
+	r := v.Compare(&c.version)
+	switch c.op {
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	case "!=":
+		return r != 0
+	}
+	return r == 0
+}
+
+// comparatorSet comparators which all have to hold
+type comparatorSet struct {
+	comparators []comparator
+	never       bool
+}
+
+func (s *comparatorSet) check(v *Version) bool {
+	if s.never {
+		return false
+	}
+	for _, c := range s.comparators {
+		if !c.check(v) {
+			return false
+		}
+	}
+	if len(v.Prerelease) == 0 {
+		return true
+	}
+
+	// pre-releases only satisfy sets explicitly opting into the same major.minor.patch
+	for _, c := range s.comparators {
+		if len(c.version.Prerelease) > 0 &&
+			c.version.Major == v.Major && c.version.Minor == v.Minor && c.version.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+// Constraint range expression i.e. `>=3.4.0 <4`, `^3.4 || ~2.9.1`, `3.x`, `!=3.5.2`
+// comparators separated by spaces or commas must all hold, `||` separates alternatives
+type Constraint struct {
+	source string
+	sets   []*comparatorSet
+}
+
+// ParseConstraint parses a range expression
+func ParseConstraint(s string) (*Constraint, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, errors.New("empty constraint")
+	}
+
+	c := &Constraint{source: s}
+	for _, alt := range strings.Split(s, "||") {
+		set, err := parseSet(alt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid constraint %q", s)
+		}
+		c.sets = append(c.sets, set)
+	}
+	return c, nil
+}
+
+// String returns the constraint source
+func (c *Constraint) String() string {
+	return c.source
+}
+
+// Check reports whether the version satisfies the constraint
+func (c *Constraint) Check(v *Version) bool {
+	for _, s := range c.sets {
+		if s.check(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseSet(s string) (*comparatorSet, error) {
+	fields := strings.Fields(strings.Replace(s, ",", " ", -1))
+	if len(fields) == 0 {
+		return nil, errors.New("empty alternative")
+	}
+
+	set := &comparatorSet{}
+	for i := 0; i < len(fields); i++ {
+		op, rest := splitOperator(fields[i])
+		if rest == "" {
+			// operator separated from its version by a space i.e. `>= 3.4.0`
+			if op == "" || i+1 >= len(fields) {
+				return nil, errors.Errorf("operator %q without version", fields[i])
+			}
+			i++
+			rest = fields[i]
+		}
+
+		p, err := parsePartial(rest)
+		if err != nil {
+			return nil, err
+		}
+		if err := set.add(op, p); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+func splitOperator(s string) (string, string) {
+	for _, op := range []string{">=", "<=", "!=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(s, op) {
+			return op, s[len(op):]
+		}
+	}
+	return "", s
+}
+
+// add expands the operator applied to a possibly partial version into plain comparators
+func (s *comparatorSet) add(op string, p *partial) error {
+	var (
+		v     = p.Version
+		upper Version
+	)
+	switch p.parts {
+	case 0:
+		upper = Version{}
+	case 1:
+		upper = Version{Major: v.Major + 1}
+	case 2:
+		upper = Version{Major: v.Major, Minor: v.Minor + 1}
+	default:
+		upper = Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+
+	switch op {
+	case "", "=":
+		if p.parts == 3 {
+			s.push("=", v)
+		} else if p.parts > 0 {
+			s.push(">=", v)
+			s.push("<", upper)
+		}
+
+	case "!=":
+		if p.parts < 3 {
+			return errors.New("!= requires a complete version")
+		}
+		s.push("!=", v)
+
+	case "^":
+		switch {
+		case p.parts == 0:
+		case v.Major > 0 || p.parts == 1:
+			s.push(">=", v)
+			s.push("<", Version{Major: v.Major + 1})
+		case v.Minor > 0 || p.parts == 2:
+			s.push(">=", v)
+			s.push("<", Version{Minor: v.Minor + 1})
+		default:
+			s.push(">=", v)
+			s.push("<", Version{Patch: v.Patch + 1})
+		}
+
+	case "~":
+		switch p.parts {
+		case 0:
+		case 1:
+			s.push(">=", v)
+			s.push("<", Version{Major: v.Major + 1})
+		default:
+			s.push(">=", v)
+			s.push("<", Version{Major: v.Major, Minor: v.Minor + 1})
+		}
+
+	case ">":
+		switch p.parts {
+		case 0:
+			s.never = true
+		case 3:
+			s.push(">", v)
+		default:
+			s.push(">=", upper)
+		}
+
+	case ">=":
+		if p.parts > 0 {
+			s.push(">=", v)
+		}
+
+	case "<":
+		if p.parts == 0 {
+			s.never = true
+		} else {
+			s.push("<", v)
+		}
+
+	case "<=":
+		switch p.parts {
+		case 0:
+		case 3:
+			s.push("<=", v)
+		default:
+			s.push("<", upper)
+		}
+
+	default:
+		return errors.Errorf("unknown operator %q", op)
+	}
+	return nil
+}
+
+func (s *comparatorSet) push(op string, v Version) {
+	s.comparators = append(s.comparators, comparator{op: op, version: v})
+}
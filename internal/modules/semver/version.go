@@ -0,0 +1,157 @@
+package semver
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Version semantic version as described by https://semver.org
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+	Build      string
+}
+
+// Parse parses a complete version, a leading `v` is accepted i.e. `v3.4.0-beta.1+42`
+func Parse(s string) (*Version, error) {
+	p, err := parsePartial(s)
+	if err != nil {
+		return nil, err
+	}
+	if p.parts < 3 {
+		return nil, errors.Errorf("incomplete version %q", s)
+	}
+	return &p.Version, nil
+}
+
+// String formats the version
+func (v *Version) String() string {
+	s := strconv.FormatUint(v.Major, 10) + "." + strconv.FormatUint(v.Minor, 10) + "." + strconv.FormatUint(v.Patch, 10)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is lower, equal or greater than o, build metadata is ignored
+func (v *Version) Compare(o *Version) int {
+	if c := compareUint(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+
+	// a pre-release has lower precedence than its normal version
+	switch {
+	case len(v.Prerelease) == 0 && len(o.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(o.Prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.Prerelease) && i < len(o.Prerelease); i++ {
+		if c := compareIdentifier(v.Prerelease[i], o.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareUint(uint64(len(v.Prerelease)), uint64(len(o.Prerelease)))
+}
+
+// partial version where missing or wildcard (`x`, `*`) components are not counted in parts
+type partial struct {
+	Version
+	parts int
+}
+
+func parsePartial(s string) (*partial, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if s == "" {
+		return nil, errors.New("empty version")
+	}
+
+	p := &partial{}
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		p.Build, s = s[i+1:], s[:i]
+		if p.Build == "" {
+			return nil, errors.New("empty build metadata")
+		}
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		pre := s[i+1:]
+		s = s[:i]
+		if pre == "" {
+			return nil, errors.New("empty pre-release")
+		}
+		p.Prerelease = strings.Split(pre, ".")
+		for _, id := range p.Prerelease {
+			if id == "" {
+				return nil, errors.Errorf("empty pre-release identifier in %q", pre)
+			}
+		}
+	}
+
+	components := strings.Split(s, ".")
+	if len(components) > 3 {
+		return nil, errors.Errorf("invalid version %q", s)
+	}
+	for i, c := range components {
+		if c == "x" || c == "X" || c == "*" {
+			break
+		}
+		n, err := strconv.ParseUint(c, 10, 64)
+		if err != nil || (len(c) > 1 && c[0] == '0') {
+			return nil, errors.Errorf("invalid version component %q", c)
+		}
+		switch i {
+		case 0:
+			p.Major = n
+		case 1:
+			p.Minor = n
+		case 2:
+			p.Patch = n
+		}
+		p.parts++
+	}
+	if p.parts < 3 && len(p.Prerelease) > 0 {
+		return nil, errors.Errorf("pre-release requires a complete version")
+	}
+	return p, nil
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareIdentifier numeric identifiers compare numerically and always have lower precedence than alphanumeric ones
+func compareIdentifier(a, b string) int {
+	an, aerr := strconv.ParseUint(a, 10, 64)
+	bn, berr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aerr == nil && berr == nil:
+		return compareUint(an, bn)
+	case aerr == nil:
+		return -1
+	case berr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,205 @@
+package simulation
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxReplayFlips limits how many flipped records are kept in a replay report, counts are not limited
+	MaxReplayFlips = 1000
+
+	maxRecordSize = 1 << 20
+)
+
+// Record past authorization decision, Outcome is one of warden outcomes
+// Time is the unix time the decision was taken at, records without it are replayed at the current time
+type Record struct {
+	Request  ladon.Request `json:"request" bson:"request"`
+	Outcome  string        `json:"outcome" bson:"outcome"`
+	Deciders []string      `json:"deciders" bson:"deciders"`
+	Time     int64         `json:"time" bson:"time"`
+}
+
+// RecordIterator stream of past decisions, Next returns io.EOF once exhausted
+type RecordIterator interface {
+	Next() (*Record, error)
+}
+
+// Flip past decision along with the decision of candidate policies
+type Flip struct {
+	Record   Record   `json:"record"`
+	Outcome  string   `json:"outcome"`
+	Deciders []string `json:"deciders"`
+}
+
+// FlipGroup counts flipped decisions sharing a subject, resource or policy
+// Granted counts requests refused in the past and allowed now, Revoked the opposite
+type FlipGroup struct {
+	Key     string `json:"key"`
+	Flipped int    `json:"flipped"`
+	Granted int    `json:"granted"`
+	Revoked int    `json:"revoked"`
+}
+
+// ReplayReport outcome of a replay, groups are ordered by flipped count
+// Flips holds at most MaxReplayFlips of flipped records
+type ReplayReport struct {
+	Replayed   int         `json:"replayed"`
+	Flipped    int         `json:"flipped"`
+	BySubject  []FlipGroup `json:"by_subject"`
+	ByResource []FlipGroup `json:"by_resource"`
+	ByPolicy   []FlipGroup `json:"by_policy"`
+	Flips      []Flip      `json:"flips"`
+}
+
+// Replay evaluates every past decision with candidate policies at the time it was taken, resolvers of the
+// warden apply as well, a flip is grouped under policies which decided it in the past or with candidate policies
+// policy revisions are not stored so decisions are compared against candidate policies only
+func Replay(w *warden.Warden, candidate ladon.Manager, records RecordIterator) (*ReplayReport, error) {
+	var at time.Time
+	replayer := detach(w)
+	replayer.Manager = ordered{candidate}
+	replayer.Now = func() time.Time { return at }
+
+	var (
+		report     = &ReplayReport{Flips: make([]Flip, 0)}
+		bySubject  = make(map[string]*FlipGroup)
+		byResource = make(map[string]*FlipGroup)
+		byPolicy   = make(map[string]*FlipGroup)
+	)
+	for {
+		rec, err := records.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed reading decision records")
+		}
+
+		at = time.Now()
+		if rec.Time > 0 {
+			at = time.Unix(rec.Time, 0)
+		}
+		r := rec.Request
+		d, err := replayer.Decide(&r)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed replaying decision")
+		}
+		report.Replayed++
+		if d.Outcome() == rec.Outcome {
+			continue
+		}
+
+		report.Flipped++
+		granted := d.Allowed
+		count(bySubject, granted, rec.Request.Subject)
+		count(byResource, granted, rec.Request.Resource)
+		count(byPolicy, granted, union(rec.Deciders, d.Deciders)...)
+		if len(report.Flips) < MaxReplayFlips {
+			report.Flips = append(report.Flips, Flip{Record: *rec, Outcome: d.Outcome(), Deciders: d.Deciders})
+		}
+	}
+
+	report.BySubject = groups(bySubject)
+	report.ByResource = groups(byResource)
+	report.ByPolicy = groups(byPolicy)
+	return report, nil
+}
+
+// Records iterates over records held in memory
+func Records(records []Record) RecordIterator {
+	return &sliceIterator{records: records}
+}
+
+// NewJSONRecordReader iterates over records stored as JSON lines
+func NewJSONRecordReader(r io.Reader) RecordIterator {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64<<10), maxRecordSize)
+	return &jsonIterator{scanner: s}
+}
+
+type sliceIterator struct {
+	records []Record
+	next    int
+}
+
+func (it *sliceIterator) Next() (*Record, error) {
+	if it.next >= len(it.records) {
+		return nil, io.EOF
+	}
+	it.next++
+	return &it.records[it.next-1], nil
+}
+
+type jsonIterator struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (it *jsonIterator) Next() (*Record, error) {
+	for it.scanner.Scan() {
+		it.line++
+		if len(it.scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(it.scanner.Bytes(), &rec); err != nil {
+			return nil, errors.Wrapf(err, "invalid record on line %d", it.line)
+		}
+		return &rec, nil
+	}
+	if err := it.scanner.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return nil, io.EOF
+}
+
+func count(gs map[string]*FlipGroup, granted bool, keys ...string) {
+	for _, k := range keys {
+		g, ok := gs[k]
+		if !ok {
+			g = &FlipGroup{Key: k}
+			gs[k] = g
+		}
+		g.Flipped++
+		if granted {
+			g.Granted++
+		} else {
+			g.Revoked++
+		}
+	}
+}
+
+func groups(gs map[string]*FlipGroup) []FlipGroup {
+	out := make([]FlipGroup, 0, len(gs))
+	for _, g := range gs {
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Flipped != out[j].Flipped {
+			return out[i].Flipped > out[j].Flipped
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+func union(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	out := make([]string, 0, len(a)+len(b))
+	for _, s := range append(append([]string{}, a...), b...) {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,204 @@
+package simulation
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"github.com/pkg/errors"
+)
+
+const (
+	pageSize = 100
+)
+
+var (
+	// ErrChangeInvalid is returned when a change set does not apply to current policies
+	ErrChangeInvalid = errors.New("change set does not apply to current policies")
+)
+
+// RequestLog provides recently evaluated requests to simulate changes against
+type RequestLog interface {
+	RecentRequests(limit int) ([]ladon.Request, error)
+}
+
+// ChangeSet proposed policies to create, update and delete
+type ChangeSet struct {
+	Create []*ladon.DefaultPolicy `json:"create"`
+	Update []*ladon.DefaultPolicy `json:"update"`
+	Delete []string               `json:"delete"`
+}
+
+// Result decisions of a request before and after a change
+type Result struct {
+	Request        ladon.Request `json:"request"`
+	Before         string        `json:"before"`
+	After          string        `json:"after"`
+	Flipped        bool          `json:"flipped"`
+	BeforeDeciders []string      `json:"before_deciders"`
+	AfterDeciders  []string      `json:"after_deciders"`
+}
+
+// Report outcome of a simulation, Flips lists results of requests whose decision would change
+type Report struct {
+	Evaluated int      `json:"evaluated"`
+	Flipped   int      `json:"flipped"`
+	Flips     []Result `json:"flips"`
+}
+
+// Snapshot copies every policy of the manager into memory, the manager has to page policies in a stable order
+func Snapshot(m ladon.Manager) (*memory.MemoryManager, error) {
+	snapshot := memory.NewMemoryManager()
+	for offset := int64(0); ; offset += pageSize {
+		ps, err := m.GetAll(pageSize, offset)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed retrieving policies")
+		}
+		for _, p := range ps {
+			if err := snapshot.Create(p); err != nil {
+				return nil, errors.Wrapf(err, "failed copying policy %s", p.GetID())
+			}
+		}
+		if len(ps) < pageSize {
+			break
+		}
+	}
+	return snapshot, nil
+}
+
+// Overlay applies the change set on an in-memory copy of policies of the manager, the manager is left untouched
+func Overlay(m ladon.Manager, cs *ChangeSet) (ladon.Manager, error) {
+	overlay, err := Snapshot(m)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range cs.Delete {
+		if _, ok := overlay.Policies[id]; !ok {
+			return nil, errors.Wrapf(ErrChangeInvalid, "deleted policy %s does not exist", id)
+		}
+		if err := overlay.Delete(id); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	for _, p := range cs.Update {
+		if _, ok := overlay.Policies[p.ID]; !ok {
+			return nil, errors.Wrapf(ErrChangeInvalid, "updated policy %s does not exist", p.ID)
+		}
+		if err := overlay.Update(p); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	for _, p := range cs.Create {
+		if p.ID == "" {
+			return nil, errors.Wrap(ErrChangeInvalid, "created policy requires an id")
+		}
+		if _, ok := overlay.Policies[p.ID]; ok {
+			return nil, errors.Wrapf(ErrChangeInvalid, "created policy %s already exists", p.ID)
+		}
+		if err := overlay.Create(p); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return overlay, nil
+}
+
+// Simulate evaluates requests with policies of the warden before and after the change set
+// the warden is not modified and nothing is written to its audit logger
+func Simulate(w *warden.Warden, cs *ChangeSet, requests []ladon.Request) (*Report, error) {
+	current, err := Snapshot(w.Manager)
+	if err != nil {
+		return nil, err
+	}
+	overlay, err := Overlay(current, cs)
+	if err != nil {
+		return nil, err
+	}
+	return compareAll(w, current, overlay, requests)
+}
+
+// Compare evaluates requests with policies of the warden and with candidate policies
+// both are evaluated in memory so lookup differences of the warden manager do not show up as flips
+func Compare(w *warden.Warden, candidate ladon.Manager, requests []ladon.Request) (*Report, error) {
+	current, err := Snapshot(w.Manager)
+	if err != nil {
+		return nil, err
+	}
+	return compareAll(w, current, candidate, requests)
+}
+
+func compareAll(w *warden.Warden, current, candidate ladon.Manager, requests []ladon.Request) (*Report, error) {
+	before, after := detach(w), detach(w)
+	// both sides decide at the same instant so time relative conditions can not flip a decision
+	now := time.Now()
+	if w.Now != nil {
+		now = w.Now()
+	}
+	before.Now = func() time.Time { return now }
+	after.Now = before.Now
+	before.Manager = ordered{current}
+	after.Manager = ordered{candidate}
+
+	report := &Report{Flips: make([]Result, 0)}
+	for _, r := range requests {
+		res, err := compare(before, after, r)
+		if err != nil {
+			return nil, err
+		}
+		report.Evaluated++
+		if res.Flipped {
+			report.Flipped++
+			report.Flips = append(report.Flips, *res)
+		}
+	}
+	return report, nil
+}
+
+func compare(before, after *warden.Warden, r ladon.Request) (*Result, error) {
+	db, err := before.Decide(&r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed evaluating request with current policies")
+	}
+	da, err := after.Decide(&r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed evaluating request with candidate policies")
+	}
+	return &Result{
+		Request:        r,
+		Before:         db.Outcome(),
+		After:          da.Outcome(),
+		Flipped:        db.Outcome() != da.Outcome(),
+		BeforeDeciders: db.Deciders,
+		AfterDeciders:  da.Deciders,
+	}, nil
+}
+
+// detach copies the warden so simulated decisions are neither traced nor audited
+// conditions with side effects such as rate limits and webhooks are assumed fulfilled
+func detach(w *warden.Warden) *warden.Warden {
+	c := *w
+	// the manager is swapped for in-memory ones, its separator has to be kept on the warden
+	c.ResourceSeparator = w.Separator()
+	c.AuditLogger = ladon.DefaultAuditLogger
+	c.Trace = false
+	c.DryRun = true
+	return &c
+}
+
+// ordered evaluates candidates of a manager sorted by id so deciders of a decision are stable across runs
+type ordered struct {
+	ladon.Manager
+}
+
+func (m ordered) FindRequestCandidates(r *ladon.Request) (ladon.Policies, error) {
+	ps, err := m.Manager.FindRequestCandidates(r)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].GetID() < ps[j].GetID()
+	})
+	return ps, nil
+}
@@ -0,0 +1,132 @@
+package subjects
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMaxDepth limits how many levels of parent groups are expanded
+	DefaultMaxDepth = 16
+)
+
+var (
+	// ErrMembershipInvalidParameter is ...
+	ErrMembershipInvalidParameter = errors.New("membership contains invalid parameter")
+
+	// ErrMembershipCycle is returned when a membership would make a group a member of itself
+	ErrMembershipCycle = errors.New("membership would create a cycle")
+
+	// ErrMembershipTooDeep is returned when memberships nest deeper than the maximum depth
+	ErrMembershipTooDeep = errors.New("memberships nest deeper than the maximum depth")
+
+	// ErrMembershipNotFound is ...
+	ErrMembershipNotFound = errors.New("requested membership could not be found")
+)
+
+// Store persists memberships of a merchant
+type Store interface {
+	Insert(member, group string) error
+	Delete(member, group string) error
+	GroupsOf(members ...string) ([]string, error)
+	MembersOf(groups ...string) ([]string, error)
+}
+
+// Hierarchy resolves users into groups and roles, and groups into their parent groups
+type Hierarchy struct {
+	Store    Store
+	MaxDepth int
+}
+
+// NewHierarchy is ...
+func NewHierarchy(s Store) *Hierarchy {
+	return &Hierarchy{Store: s, MaxDepth: DefaultMaxDepth}
+}
+
+// Add makes member belong to group, refusing memberships which would create a cycle
+func (h *Hierarchy) Add(member, group string) error {
+	if member == "" || group == "" {
+		return errors.Wrap(ErrMembershipInvalidParameter, "member and group are required")
+	}
+	if member == group {
+		return errors.Wrapf(ErrMembershipCycle, "%s can not be a member of itself", member)
+	}
+
+	// every ancestor is checked regardless of the maximum depth
+	ancestors, err := h.walk(group, h.Store.GroupsOf, 0)
+	if err != nil {
+		return errors.Wrapf(err, "failed expanding group %s", group)
+	}
+	for _, a := range ancestors {
+		if a == member {
+			return errors.Wrapf(ErrMembershipCycle, "%s already belongs to %s", group, member)
+		}
+	}
+
+	return h.Store.Insert(member, group)
+}
+
+// Remove membership of member in group
+func (h *Hierarchy) Remove(member, group string) error {
+	return h.Store.Delete(member, group)
+}
+
+// Expand returns the subject followed by every group it transitively belongs to, nearest first
+// memberships looping back to an already visited group are ignored, groups nested deeper than
+// MaxDepth fail the expansion with ErrMembershipTooDeep rather than being left out
+func (h *Hierarchy) Expand(subject string) ([]string, error) {
+	ancestors, err := h.walk(subject, h.Store.GroupsOf, h.maxDepth())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed expanding subject %s", subject)
+	}
+	return append([]string{subject}, ancestors...), nil
+}
+
+// Members returns every user or group transitively belonging to the group, nearest first
+// members nested deeper than MaxDepth fail with ErrMembershipTooDeep
+func (h *Hierarchy) Members(group string) ([]string, error) {
+	members, err := h.walk(group, h.Store.MembersOf, h.maxDepth())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed retrieving members of %s", group)
+	}
+	return members, nil
+}
+
+// walk returns subjects reachable from start level by level through next, nearest first
+// a positive limit bounds the number of levels, anything reachable beyond it is an error
+func (h *Hierarchy) walk(start string, next func(...string) ([]string, error), limit int) ([]string, error) {
+	var (
+		found   []string
+		visited = map[string]bool{start: true}
+		level   = []string{start}
+	)
+
+	for depth := 0; len(level) > 0; depth++ {
+		subjects, err := next(level...)
+		if err != nil {
+			return nil, err
+		}
+
+		unvisited := make([]string, 0, len(subjects))
+		for _, s := range subjects {
+			if visited[s] {
+				continue
+			}
+			visited[s] = true
+			unvisited = append(unvisited, s)
+		}
+		if limit > 0 && depth >= limit && len(unvisited) > 0 {
+			return nil, errors.Wrapf(ErrMembershipTooDeep, "more than %d levels", limit)
+		}
+		found = append(found, unvisited...)
+		level = unvisited
+	}
+
+	return found, nil
+}
+
+func (h *Hierarchy) maxDepth() int {
+	if h.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return h.MaxDepth
+}
@@ -0,0 +1,96 @@
+package subjects
+
+import (
+	"context"
+	"fmt"
+
+	model "github.com/ndv6/gate/internal/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// MembershipTableSuffix ...
+	MembershipTableSuffix = "_memberships"
+)
+
+// MongoMembershipStore is ...
+type MongoMembershipStore struct {
+	db *mongo.Collection
+}
+
+// NewMongoMembershipStore is ...
+func NewMongoMembershipStore(merchant string, db *mongo.Database) *MongoMembershipStore {
+	return &MongoMembershipStore{db: db.Collection(fmt.Sprintf("%s%s", merchant, MembershipTableSuffix))}
+}
+
+// Insert membership, inserting an existing one does nothing
+func (ms *MongoMembershipStore) Insert(member, group string) error {
+	m := model.NewMembership(member, group)
+	query := bson.M{"member": member, "group": group}
+	update := bson.M{"$setOnInsert": bson.M{
+		"_id":        primitive.NewObjectID().Hex(),
+		"created_at": m.CreatedAt,
+	}}
+
+	if _, err := ms.db.UpdateOne(context.TODO(), query, update, options.Update().SetUpsert(true)); err != nil {
+		return errors.Wrap(err, "failed creating new membership")
+	}
+	return nil
+}
+
+// Delete membership
+func (ms *MongoMembershipStore) Delete(member, group string) error {
+	r, err := ms.db.DeleteOne(context.TODO(), bson.M{"member": member, "group": group})
+	if err != nil {
+		return errors.Wrap(err, "failed deleting membership")
+	}
+
+	if r.DeletedCount == 0 {
+		return errors.Wrapf(ErrMembershipNotFound, "%s is not a member of %s", member, group)
+	}
+	return nil
+}
+
+// GroupsOf returns groups any of given members directly belongs to
+func (ms *MongoMembershipStore) GroupsOf(members ...string) ([]string, error) {
+	if len(members) == 0 {
+		return nil, nil
+	}
+
+	groups, err := ms.db.Distinct(context.TODO(), "group", bson.M{"member": bson.M{"$in": members}})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed retrieving groups of members")
+	}
+
+	var gs []string
+	for _, g := range groups {
+		if s, ok := g.(string); ok {
+			gs = append(gs, s)
+		}
+	}
+	return gs, nil
+}
+
+// MembersOf returns users or groups directly belonging to any of given groups
+func (ms *MongoMembershipStore) MembersOf(groups ...string) ([]string, error) {
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	members, err := ms.db.Distinct(context.TODO(), "member", bson.M{"group": bson.M{"$in": groups}})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed retrieving members of groups")
+	}
+
+	var mm []string
+	for _, m := range members {
+		if s, ok := m.(string); ok {
+			mm = append(mm, s)
+		}
+	}
+	return mm, nil
+}
@@ -0,0 +1,80 @@
+package warden
+
+import (
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+// ConditionResult details reported by a condition while evaluating a policy i.e. a risk score
+type ConditionResult struct {
+	Policy  string                 `json:"policy"`
+	Key     string                 `json:"key"`
+	Type    string                 `json:"type"`
+	Passed  bool                   `json:"passed"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+const (
+	// OutcomeAllow request is allowed
+	OutcomeAllow = "allow"
+
+	// OutcomeDeny request is refused
+	OutcomeDeny = "deny"
+
+	// OutcomeStepUp request is refused until the caller authenticates again
+	OutcomeStepUp = "step_up"
+)
+
+// PolicyTrace how an evaluated policy was handled, Reason tells why a policy did not apply
+type PolicyTrace struct {
+	Policy  string `json:"policy"`
+	Effect  string `json:"effect"`
+	Applied bool   `json:"applied"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Decision outcome of an access request
+// Deciders lists ids of policies which made the decision, Matched ids of policies whose subjects,
+// resources and actions match the request regardless of their conditions
+// StepUpRequired tells the caller to authenticate again instead of treating the request as plainly denied
+// Conditions holds details reported by conditions for auditing
+// Subjects lists the request subject along with groups and roles it belongs to, when resolved
+// Actions lists the request action along with action groups including it, when resolved
+// Resources lists the request resource along with its ancestors, when resources are hierarchical
+// Trace lists evaluated policies in order, when tracing is enabled
+type Decision struct {
+	Allowed        bool              `json:"allowed"`
+	ExplicitDeny   bool              `json:"explicit_deny"`
+	StepUpRequired bool              `json:"step_up_required"`
+	Deciders       []string          `json:"deciders"`
+	Matched        []string          `json:"matched,omitempty"`
+	Conditions     []ConditionResult `json:"conditions,omitempty"`
+	Subjects       []string          `json:"subjects,omitempty"`
+	Actions        []string          `json:"actions,omitempty"`
+	Resources      []string          `json:"resources,omitempty"`
+	Trace          []PolicyTrace     `json:"trace,omitempty"`
+}
+
+// Outcome summarizes the decision as one of OutcomeAllow, OutcomeDeny or OutcomeStepUp
+func (d *Decision) Outcome() string {
+	switch {
+	case d.Allowed:
+		return OutcomeAllow
+	case d.StepUpRequired:
+		return OutcomeStepUp
+	}
+	return OutcomeDeny
+}
+
+// Err maps the decision into the errors returned by ladon
+func (d *Decision) Err() error {
+	switch {
+	case d.Allowed:
+		return nil
+	case d.ExplicitDeny:
+		return errors.WithStack(ladon.ErrRequestForcefullyDenied)
+	case d.StepUpRequired:
+		return errors.WithStack(ErrStepUpRequired)
+	}
+	return errors.WithStack(ladon.ErrRequestDenied)
+}
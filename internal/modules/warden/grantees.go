@@ -0,0 +1,196 @@
+package warden
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+// Grantee subject or subject pattern allowed to perform an action on a resource
+// Conditional tells the access depends on conditions, Policies lists ids of policies granting it and
+// Restrictions ids of deny policies applying to part of the subject pattern or only under some conditions
+// Members lists users and groups belonging to the subject which keep the access, when memberships are resolved
+type Grantee struct {
+	Subject      string   `json:"subject"`
+	Conditional  bool     `json:"conditional"`
+	Policies     []string `json:"policies"`
+	Restrictions []string `json:"restrictions,omitempty"`
+	Members      []string `json:"members,omitempty"`
+}
+
+// Grantees lists subjects allowed to perform the action on the resource after deny overrides
+func (w *Warden) Grantees(resource, action string) ([]Grantee, error) {
+	e, err := w.expand(&ladon.Request{Resource: resource, Action: action})
+	if err != nil {
+		return nil, err
+	}
+	ps, err := w.resourcePolicies(e)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		grantees = make(map[string]*Grantee)
+		denies   ladon.Policies
+	)
+	for _, p := range ps {
+		if !p.AllowAccess() {
+			denies = append(denies, p)
+			continue
+		}
+		_, conditional := w.applies(p, "", resource, action, nil)
+		for _, s := range p.GetSubjects() {
+			g, ok := grantees[s]
+			if !ok {
+				g = &Grantee{Subject: s, Conditional: true}
+				grantees[s] = g
+			}
+			g.Policies = append(g.Policies, p.GetID())
+			g.Conditional = g.Conditional && conditional
+		}
+	}
+
+	out := make([]Grantee, 0, len(grantees))
+	for _, g := range grantees {
+		granted, err := w.restrictGrantee(g, denies, resource, action)
+		if err != nil {
+			return nil, err
+		}
+		if granted {
+			out = append(out, *g)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Subject < out[j].Subject
+	})
+	return out, nil
+}
+
+// restrictGrantee applies deny policies to the grantee and resolves its members keeping the access
+func (w *Warden) restrictGrantee(g *Grantee, denies ladon.Policies, resource, action string) (bool, error) {
+	subjects, err := w.expandSubject(g.Subject)
+	if err != nil {
+		return false, err
+	}
+
+	for _, d := range denies {
+		_, conditional := w.applies(d, g.Subject, resource, action, nil)
+		covered, err := w.matchesAny(d, d.GetSubjects(), subjects)
+		if err != nil {
+			return false, err
+		}
+		if covered && !conditional {
+			return false, nil
+		}
+		if covered {
+			g.Conditional = true
+			g.Restrictions = append(g.Restrictions, d.GetID())
+			continue
+		}
+
+		pattern := &ladon.DefaultPolicy{Subjects: []string{g.Subject}}
+		overlaps, err := w.matchesAny(pattern, pattern.Subjects, d.GetSubjects())
+		if err != nil {
+			return false, err
+		}
+		if overlaps {
+			g.Restrictions = append(g.Restrictions, d.GetID())
+		}
+	}
+
+	mr, ok := w.Subjects.(MemberResolver)
+	if !ok {
+		return true, nil
+	}
+	members, err := mr.Members(g.Subject)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed resolving members of %s", g.Subject)
+	}
+	for _, m := range members {
+		denied, err := w.deniedMember(m, denies, resource, action)
+		if err != nil {
+			return false, err
+		}
+		if !denied {
+			g.Members = append(g.Members, m)
+		}
+	}
+	return true, nil
+}
+
+// deniedMember reports whether an unconditional deny applies to the member or any group it belongs to
+func (w *Warden) deniedMember(member string, denies ladon.Policies, resource, action string) (bool, error) {
+	subjects, err := w.expandSubject(member)
+	if err != nil {
+		return false, err
+	}
+	for _, d := range denies {
+		if _, conditional := w.applies(d, member, resource, action, nil); conditional {
+			continue
+		}
+		covered, err := w.matchesAny(d, d.GetSubjects(), subjects)
+		if err != nil || covered {
+			return covered, err
+		}
+	}
+	return false, nil
+}
+
+// resourcePolicies looks policies up for the resource and its ancestors keeping those matching the request
+func (w *Warden) resourcePolicies(e *expansion) (ladon.Policies, error) {
+	var (
+		found = ladon.Policies{}
+		seen  = make(map[string]bool)
+	)
+	for _, r := range e.resources {
+		ps, err := w.Manager.FindPoliciesForResource(r)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range ps {
+			if seen[p.GetID()] {
+				continue
+			}
+			seen[p.GetID()] = true
+			if ok, err := w.matchesAny(p, p.GetActions(), e.actions); err != nil {
+				return nil, err
+			} else if !ok {
+				continue
+			}
+			if ok, err := w.matchesAny(p, p.GetResources(), e.resources); err != nil {
+				return nil, err
+			} else if ok {
+				found = append(found, p)
+			}
+		}
+	}
+	return found, nil
+}
+
+// WriteGranteesCSV exports grantees as CSV, list columns are joined with `;`
+func WriteGranteesCSV(out io.Writer, grantees []Grantee) error {
+	cw := csv.NewWriter(out)
+	if err := cw.Write([]string{"subject", "conditional", "policies", "restrictions", "members"}); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, g := range grantees {
+		record := []string{
+			g.Subject,
+			strconv.FormatBool(g.Conditional),
+			strings.Join(g.Policies, ";"),
+			strings.Join(g.Restrictions, ";"),
+			strings.Join(g.Members, ";"),
+		}
+		if err := cw.Write(record); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	cw.Flush()
+	return errors.WithStack(cw.Error())
+}
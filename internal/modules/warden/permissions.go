@@ -0,0 +1,216 @@
+package warden
+
+import (
+	"sort"
+
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+// Permission resource and action a subject is allowed on, both may be patterns as written in policies
+// Conditional tells the grant depends on conditions which could not be decided without a request context
+// Policies lists ids of policies granting it, Restrictions ids of deny policies applying to part of it
+// or only under some conditions
+type Permission struct {
+	Resource     string   `json:"resource"`
+	Action       string   `json:"action"`
+	Conditional  bool     `json:"conditional"`
+	Policies     []string `json:"policies"`
+	Restrictions []string `json:"restrictions,omitempty"`
+}
+
+type grant struct {
+	policies    []string
+	conditional bool
+}
+
+// EffectivePermissions enumerates what the subject is allowed to do after deny overrides
+// without a context every policy having conditions is reported as conditional, with a context
+// conditions are evaluated against it and the resource and action of the grant
+func (w *Warden) EffectivePermissions(subject string, ctx ladon.Context) ([]Permission, error) {
+	e, err := w.expand(&ladon.Request{Subject: subject})
+	if err != nil {
+		return nil, err
+	}
+	ps, err := w.subjectPolicies(e.subjects)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		grants = make(map[[2]string]*grant)
+		keys   [][2]string
+		denies ladon.Policies
+	)
+	for _, p := range ps {
+		if !p.AllowAccess() {
+			denies = append(denies, p)
+			continue
+		}
+		for _, resource := range p.GetResources() {
+			for _, action := range p.GetActions() {
+				applies, conditional := w.applies(p, subject, resource, action, ctx)
+				if !applies {
+					continue
+				}
+				key := [2]string{resource, action}
+				g, ok := grants[key]
+				if !ok {
+					g = &grant{conditional: true}
+					grants[key] = g
+					keys = append(keys, key)
+				}
+				g.policies = append(g.policies, p.GetID())
+				g.conditional = g.conditional && conditional
+			}
+		}
+	}
+
+	permissions := make([]Permission, 0, len(keys))
+	for _, key := range keys {
+		perm, granted, err := w.restrict(key[0], key[1], grants[key], denies, subject, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if granted {
+			permissions = append(permissions, perm)
+		}
+	}
+
+	sort.Slice(permissions, func(i, j int) bool {
+		if permissions[i].Resource != permissions[j].Resource {
+			return permissions[i].Resource < permissions[j].Resource
+		}
+		return permissions[i].Action < permissions[j].Action
+	})
+	return permissions, nil
+}
+
+// restrict applies deny policies to a grant, an unconditional deny covering the whole grant revokes it
+func (w *Warden) restrict(resource, action string, g *grant, denies ladon.Policies, subject string, ctx ladon.Context) (Permission, bool, error) {
+	perm := Permission{Resource: resource, Action: action, Conditional: g.conditional, Policies: g.policies}
+	actions := []string{action}
+	if w.Actions != nil {
+		expanded, err := w.Actions.Expand(action)
+		if err != nil {
+			return perm, false, errors.Wrap(err, "failed resolving action groups")
+		}
+		actions = expanded
+	}
+
+	for _, d := range denies {
+		applies, conditional := w.applies(d, subject, resource, action, ctx)
+		if !applies {
+			continue
+		}
+		covered, err := w.covers(d, resource, actions)
+		if err != nil {
+			return perm, false, err
+		}
+		if covered && !conditional {
+			return perm, false, nil
+		}
+		if covered {
+			perm.Conditional = true
+			perm.Restrictions = append(perm.Restrictions, d.GetID())
+			continue
+		}
+		overlaps, err := w.overlaps(d, resource, action)
+		if err != nil {
+			return perm, false, err
+		}
+		if overlaps {
+			perm.Restrictions = append(perm.Restrictions, d.GetID())
+		}
+	}
+	return perm, true, nil
+}
+
+// subjectPolicies looks policies up for every expanded subject keeping those matching any of them
+func (w *Warden) subjectPolicies(subjects []string) (ladon.Policies, error) {
+	var (
+		found = ladon.Policies{}
+		seen  = make(map[string]bool)
+	)
+	for _, s := range subjects {
+		ps, err := w.Manager.FindPoliciesForSubject(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range ps {
+			if seen[p.GetID()] {
+				continue
+			}
+			seen[p.GetID()] = true
+			ok, err := w.matchesAny(p, p.GetSubjects(), subjects)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				found = append(found, p)
+			}
+		}
+	}
+	return found, nil
+}
+
+// applies evaluates conditions of the policy when a context is given, otherwise a policy
+// having conditions applies conditionally; conditions with side effects are never evaluated
+// by these read-only queries, a policy having any applies conditionally
+func (w *Warden) applies(p ladon.Policy, subject, resource, action string, ctx ladon.Context) (applies bool, conditional bool) {
+	if len(p.GetConditions()) == 0 {
+		return true, false
+	}
+	if ctx == nil {
+		return true, true
+	}
+	r := w.at(&ladon.Request{Subject: subject, Resource: resource, Action: action, Context: ctx})
+	for key, c := range p.GetConditions() {
+		if hasSideEffects(c) {
+			conditional = true
+			continue
+		}
+		if !c.Fulfills(ctx[key], r) {
+			return false, false
+		}
+	}
+	return true, conditional
+}
+
+// covers reports whether the policy applies to the whole of resource and action
+func (w *Warden) covers(p ladon.Policy, resource string, actions []string) (bool, error) {
+	if ok, err := w.matchesAny(p, p.GetActions(), actions); err != nil || !ok {
+		return false, err
+	}
+	return w.matchesAny(p, p.GetResources(), policies.Ancestors(resource, w.Separator()))
+}
+
+// overlaps reports whether the policy applies to part of the resource and action patterns
+// i.e. a deny on `room:5` within an allow on `room:<.*>`
+func (w *Warden) overlaps(p ladon.Policy, resource, action string) (bool, error) {
+	pattern := &ladon.DefaultPolicy{Resources: []string{resource}, Actions: []string{action}}
+
+	actionOverlaps := false
+	for _, a := range p.GetActions() {
+		ok, err := w.matcher().Matches(pattern, pattern.Actions, a)
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+		if ok {
+			actionOverlaps = true
+			break
+		}
+	}
+	if !actionOverlaps {
+		return false, nil
+	}
+
+	for _, r := range p.GetResources() {
+		ok, err := w.matchesAny(pattern, pattern.Resources, policies.Ancestors(r, w.Separator()))
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
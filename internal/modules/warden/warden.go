@@ -0,0 +1,432 @@
+package warden
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+// DecisionTimeKey context key conditions read the unix time a request is decided at from, the warden sets it
+// on the context conditions are evaluated with, replacing any value given by the caller
+const DecisionTimeKey = "decision_time"
+
+var (
+	// ErrStepUpRequired is returned when the request would be allowed once the caller authenticates again
+	ErrStepUpRequired = errors.New("request requires step-up authentication")
+
+	// ErrSeparatorMismatch is returned when the warden and its manager split resources differently
+	ErrSeparatorMismatch = errors.New("resource separator of warden and manager differ")
+)
+
+// Matcher checks whether a request attribute matches one of policy attributes
+type Matcher interface {
+	Matches(p ladon.Policy, haystack []string, needle string) (bool, error)
+}
+
+// StepUpCondition is implemented by conditions whose failure can be resolved by authenticating again
+type StepUpCondition interface {
+	StepUp() bool
+}
+
+// SideEffectCondition is implemented by conditions whose evaluation changes state or reaches external
+// services i.e. rate limits counting hits and webhooks, they are evaluated after every other condition
+// of a policy and only when those passed
+type SideEffectCondition interface {
+	SideEffects() bool
+}
+
+// Explainer is implemented by conditions reporting details of their evaluation i.e. a computed score
+type Explainer interface {
+	Explain(value interface{}, r *ladon.Request) (bool, map[string]interface{})
+}
+
+// SubjectResolver expands a subject into itself and every group or role it transitively belongs to
+type SubjectResolver interface {
+	Expand(subject string) ([]string, error)
+}
+
+// MemberResolver is implemented by subject resolvers able to list users and groups belonging to a group
+type MemberResolver interface {
+	Members(group string) ([]string, error)
+}
+
+// DecisionLogger is implemented by audit loggers recording whole decisions, the warden calls it instead of
+// the ladon.AuditLogger methods
+type DecisionLogger interface {
+	LogDecision(r *ladon.Request, d *Decision, latency time.Duration)
+}
+
+// HierarchicalManager is implemented by managers looking policies of ancestor resources up themselves
+type HierarchicalManager interface {
+	ResourceSeparator() string
+}
+
+// ActionResolver expands an action into itself and every action group transitively including it
+type ActionResolver interface {
+	Expand(action string) ([]string, error)
+}
+
+// Warden evaluates access requests the same way ladon does while reporting how the decision was made
+// when Subjects is set policies of groups and roles the request subject belongs to apply as well
+// when Actions is set policies listing action groups including the request action apply as well
+// when ResourceSeparator is set policies of ancestor resources apply to descendants, a deny on any of them wins
+// it defaults to the separator of a HierarchicalManager and must agree with it when both are set,
+// see policies.MongoPolicyManager.UseResourceSeparator
+// when Trace is set decisions explain how every evaluated policy was handled
+// when DryRun is set conditions with side effects are assumed fulfilled instead of being evaluated
+// when Now is set requests are decided at the time it returns instead of the current time i.e. to replay them
+type Warden struct {
+	Manager           ladon.Manager
+	Matcher           Matcher
+	AuditLogger       ladon.AuditLogger
+	Subjects          SubjectResolver
+	Actions           ActionResolver
+	ResourceSeparator string
+	Trace             bool
+	DryRun            bool
+	Now               func() time.Time
+}
+
+// expansion request subject, action and resource along with what they resolve to
+type expansion struct {
+	subjects  []string
+	actions   []string
+	resources []string
+}
+
+// NewWarden is ...
+func NewWarden(m ladon.Manager, l ladon.AuditLogger) *Warden {
+	if l == nil {
+		l = ladon.DefaultAuditLogger
+	}
+	return &Warden{Manager: m, Matcher: ladon.DefaultMatcher, AuditLogger: l}
+}
+
+// IsAllowed returns nil if the request is allowed, it makes Warden a drop-in replacement of ladon.Ladon
+func (w *Warden) IsAllowed(r *ladon.Request) error {
+	d, err := w.Decide(r)
+	if err != nil {
+		return err
+	}
+	return d.Err()
+}
+
+// Decide evaluates the request against policies found by the manager
+func (w *Warden) Decide(r *ladon.Request) (*Decision, error) {
+	start := time.Now()
+	e, err := w.expand(r)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := w.candidates(r, e)
+	if err != nil {
+		return nil, err
+	}
+	return w.evaluate(r, e, policies, start)
+}
+
+// Evaluate decides the request against given policies only
+func (w *Warden) Evaluate(r *ladon.Request, policies ladon.Policies) (*Decision, error) {
+	start := time.Now()
+	e, err := w.expand(r)
+	if err != nil {
+		return nil, err
+	}
+	return w.evaluate(r, e, policies, start)
+}
+
+func (w *Warden) evaluate(r *ladon.Request, e *expansion, policies ladon.Policies, start time.Time) (*Decision, error) {
+	var (
+		d        = &Decision{Deciders: make([]string, 0)}
+		deciders = ladon.Policies{}
+		stepUp   = false
+	)
+	if w.Subjects != nil {
+		d.Subjects = e.subjects
+	}
+	if w.Actions != nil {
+		d.Actions = e.actions
+	}
+	if w.Separator() != "" {
+		d.Resources = e.resources
+	}
+
+	er := w.at(r)
+	for _, p := range policies {
+		reason, err := w.mismatch(p, e)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			w.trace(d, p, reason)
+			continue
+		}
+		d.Matched = append(d.Matched, p.GetID())
+
+		failed, resolvable := w.passesConditions(p, er, d)
+		if len(failed) > 0 {
+			// an allow policy failing only on step-up conditions would grant access after re-authentication
+			if p.AllowAccess() && resolvable {
+				stepUp = true
+			}
+			w.trace(d, p, "conditions not fulfilled: "+strings.Join(failed, ", "))
+			continue
+		}
+
+		w.trace(d, p, "")
+		deciders = append(deciders, p)
+		d.Deciders = append(d.Deciders, p.GetID())
+		if !p.AllowAccess() {
+			d.Allowed, d.ExplicitDeny = false, true
+			w.audit(r, policies, deciders, d, start)
+			return d, nil
+		}
+		d.Allowed = true
+	}
+
+	if !d.Allowed {
+		d.StepUpRequired = stepUp
+	}
+	w.audit(r, policies, deciders, d, start)
+	return d, nil
+}
+
+// audit hands the decision to the audit logger, as a whole when it is a DecisionLogger
+func (w *Warden) audit(r *ladon.Request, policies, deciders ladon.Policies, d *Decision, start time.Time) {
+	l := w.auditLogger()
+	if dl, ok := l.(DecisionLogger); ok {
+		dl.LogDecision(r, d, time.Since(start))
+		return
+	}
+	if d.Allowed {
+		l.LogGrantedAccessRequest(r, policies, deciders)
+		return
+	}
+	l.LogRejectedAccessRequest(r, policies, deciders)
+}
+
+// at returns a copy of the request whose context holds the decision time, the request itself is audited as given
+func (w *Warden) at(r *ladon.Request) *ladon.Request {
+	now := time.Now()
+	if w.Now != nil {
+		now = w.Now()
+	}
+	ctx := make(ladon.Context, len(r.Context)+1)
+	for k, v := range r.Context {
+		ctx[k] = v
+	}
+	ctx[DecisionTimeKey] = now.Unix()
+
+	at := *r
+	at.Context = ctx
+	return &at
+}
+
+// candidates looks policies up for every expanded subject and action, dropping duplicates
+func (w *Warden) candidates(r *ladon.Request, e *expansion) (ladon.Policies, error) {
+	if len(e.subjects) <= 1 && len(e.actions) <= 1 {
+		return w.Manager.FindRequestCandidates(r)
+	}
+
+	var (
+		policies = ladon.Policies{}
+		seen     = make(map[string]bool)
+	)
+	for _, s := range e.subjects {
+		for _, a := range e.actions {
+			sr := *r
+			sr.Subject, sr.Action = s, a
+			ps, err := w.Manager.FindRequestCandidates(&sr)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range ps {
+				if seen[p.GetID()] {
+					continue
+				}
+				seen[p.GetID()] = true
+				policies = append(policies, p)
+			}
+		}
+	}
+	return policies, nil
+}
+
+// mismatch checks action, subject and resource in the same order as ladon and tells which one does not match
+// the policy applies to the request when any of expanded actions, subjects and resources match
+func (w *Warden) mismatch(p ladon.Policy, e *expansion) (string, error) {
+	if ok, err := w.matchesAny(p, p.GetActions(), e.actions); err != nil || !ok {
+		return "action does not match", err
+	}
+	if ok, err := w.matchesAny(p, p.GetSubjects(), e.subjects); err != nil || !ok {
+		return "subject does not match", err
+	}
+	if ok, err := w.matchesAny(p, p.GetResources(), e.resources); err != nil || !ok {
+		return "resource does not match", err
+	}
+	return "", nil
+}
+
+// passesConditions returns keys of failing conditions and whether every failing one is a step-up condition
+// like ladon it stops at the first failure unless it is a step-up one, conditions with side effects are
+// evaluated last and skipped once any other condition failed, in dry runs they are assumed fulfilled
+// details reported by explaining conditions are recorded into the decision
+func (w *Warden) passesConditions(p ladon.Policy, r *ladon.Request, d *Decision) (failed []string, resolvable bool) {
+	resolvable = true
+	for _, key := range conditionKeys(p.GetConditions()) {
+		c := p.GetConditions()[key]
+		if len(failed) > 0 && hasSideEffects(c) {
+			break
+		}
+		if w.DryRun && hasSideEffects(c) {
+			d.Conditions = append(d.Conditions, ConditionResult{
+				Policy:  p.GetID(),
+				Key:     key,
+				Type:    c.GetName(),
+				Passed:  true,
+				Details: map[string]interface{}{"assumed": true},
+			})
+			continue
+		}
+
+		var ok bool
+		if e, explains := c.(Explainer); explains {
+			var details map[string]interface{}
+			ok, details = e.Explain(r.Context[key], r)
+			d.Conditions = append(d.Conditions, ConditionResult{
+				Policy:  p.GetID(),
+				Key:     key,
+				Type:    c.GetName(),
+				Passed:  ok,
+				Details: details,
+			})
+		} else {
+			ok = c.Fulfills(r.Context[key], r)
+		}
+		if ok {
+			continue
+		}
+		failed = append(failed, key)
+		if s, ok := c.(StepUpCondition); !ok || !s.StepUp() {
+			return failed, false
+		}
+	}
+	return failed, resolvable
+}
+
+// conditionKeys returns keys of conditions in evaluation order, conditions with side effects come last
+func conditionKeys(cs ladon.Conditions) []string {
+	keys := make([]string, 0, len(cs))
+	for key := range cs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ei, ej := hasSideEffects(cs[keys[i]]), hasSideEffects(cs[keys[j]])
+		if ei != ej {
+			return ej
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func hasSideEffects(c ladon.Condition) bool {
+	s, ok := c.(SideEffectCondition)
+	return ok && s.SideEffects()
+}
+
+// trace records how the policy was handled when tracing is enabled, an empty reason means it applied
+func (w *Warden) trace(d *Decision, p ladon.Policy, reason string) {
+	if !w.Trace {
+		return
+	}
+	d.Trace = append(d.Trace, PolicyTrace{
+		Policy:  p.GetID(),
+		Effect:  p.GetEffect(),
+		Applied: reason == "",
+		Reason:  reason,
+	})
+}
+
+func (w *Warden) matchesAny(p ladon.Policy, haystack []string, needles []string) (bool, error) {
+	for _, n := range needles {
+		ok, err := w.matcher().Matches(p, haystack, n)
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Separator returns the resource separator in use, the one of the manager unless set on the warden
+func (w *Warden) Separator() string {
+	if w.ResourceSeparator != "" {
+		return w.ResourceSeparator
+	}
+	if m, ok := w.Manager.(HierarchicalManager); ok {
+		return m.ResourceSeparator()
+	}
+	return ""
+}
+
+// expand resolves subject and action of the request with resolvers which are set
+func (w *Warden) expand(r *ladon.Request) (*expansion, error) {
+	if m, ok := w.Manager.(HierarchicalManager); ok && w.ResourceSeparator != "" && m.ResourceSeparator() != w.ResourceSeparator {
+		return nil, errors.Wrapf(ErrSeparatorMismatch, "warden splits resources at %q, manager at %q", w.ResourceSeparator, m.ResourceSeparator())
+	}
+
+	e := &expansion{
+		subjects:  []string{r.Subject},
+		actions:   []string{r.Action},
+		resources: policies.Ancestors(r.Resource, w.Separator()),
+	}
+	if r.Subject != "" {
+		subjects, err := w.expandSubject(r.Subject)
+		if err != nil {
+			return nil, err
+		}
+		e.subjects = subjects
+	}
+	if w.Actions != nil && r.Action != "" {
+		actions, err := w.Actions.Expand(r.Action)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed resolving action groups")
+		}
+		e.actions = actions
+	}
+	return e, nil
+}
+
+// expandSubject resolves memberships of the subject when a resolver is set
+func (w *Warden) expandSubject(subject string) ([]string, error) {
+	if w.Subjects == nil {
+		return []string{subject}, nil
+	}
+	subjects, err := w.Subjects.Expand(subject)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed resolving subject memberships")
+	}
+	return subjects, nil
+}
+
+// matcher and auditLogger resolve defaults without writing them back, a warden may decide concurrently
+func (w *Warden) matcher() Matcher {
+	if w.Matcher == nil {
+		return ladon.DefaultMatcher
+	}
+	return w.Matcher
+}
+
+func (w *Warden) auditLogger() ladon.AuditLogger {
+	if w.AuditLogger == nil {
+		return ladon.DefaultAuditLogger
+	}
+	return w.AuditLogger
+}
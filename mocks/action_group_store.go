@@ -0,0 +1,64 @@
+package mocks
+
+import (
+	"sort"
+
+	"github.com/ndv6/gate/internal/modules/actions"
+	"github.com/pkg/errors"
+)
+
+// ActionGroupStore in-memory mock of actions.Store
+type ActionGroupStore struct {
+	Groups map[string]actions.Group
+	Err    error
+}
+
+// Save is ...
+func (m *ActionGroupStore) Save(g *actions.Group) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	if m.Groups == nil {
+		m.Groups = make(map[string]actions.Group)
+	}
+	m.Groups[g.Name] = *g
+	return nil
+}
+
+// Delete is ...
+func (m *ActionGroupStore) Delete(name string) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	if _, ok := m.Groups[name]; !ok {
+		return errors.WithStack(actions.ErrGroupNotFound)
+	}
+	delete(m.Groups, name)
+	return nil
+}
+
+// FindGroups is ...
+func (m *ActionGroupStore) FindGroups(as ...string) ([]string, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	var groups []string
+	for name, g := range m.Groups {
+		if containsAny(g.Actions, as) {
+			groups = append(groups, name)
+		}
+	}
+	sort.Strings(groups)
+	return groups, nil
+}
+
+func containsAny(haystack, needles []string) bool {
+	for _, n := range needles {
+		for _, h := range haystack {
+			if h == n {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package mocks
+
+import (
+	"sync"
+
+	"github.com/ndv6/gate/internal/modules/audit"
+)
+
+// AuditSink in-memory mock of audit.Sink and audit.Querier
+// Block, when set, holds every write until it is closed
+type AuditSink struct {
+	Entries []audit.Entry
+	Batches int
+	Block   chan struct{}
+	Err     error
+
+	mu sync.Mutex
+}
+
+// Write is ...
+func (m *AuditSink) Write(entries []*audit.Entry) error {
+	if m.Block != nil {
+		<-m.Block
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return m.Err
+	}
+	m.Batches++
+	for _, e := range entries {
+		m.Entries = append(m.Entries, *e)
+	}
+	return nil
+}
+
+// Query is ...
+func (m *AuditSink) Query(f audit.Filter) ([]audit.Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return nil, m.Err
+	}
+
+	entries := make([]audit.Entry, 0)
+	for i := len(m.Entries) - 1; i >= 0; i-- {
+		e := m.Entries[i]
+		if (f.Subject != "" && e.Request.Subject != f.Subject) ||
+			(f.Resource != "" && e.Request.Resource != f.Resource) ||
+			(f.Decision != "" && e.Outcome != f.Decision) ||
+			(f.From > 0 && e.CreatedAt < f.From) || (f.To > 0 && e.CreatedAt > f.To) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	if f.Offset >= int64(len(entries)) {
+		return entries[:0], nil
+	}
+	entries = entries[f.Offset:]
+	if f.Limit > 0 && f.Limit < int64(len(entries)) {
+		entries = entries[:f.Limit]
+	}
+	return entries, nil
+}
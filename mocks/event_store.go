@@ -0,0 +1,103 @@
+package mocks
+
+import (
+	"context"
+	"sort"
+
+	model "github.com/ndv6/gate/internal/models"
+)
+
+// EventStore in-memory mock of mongo.EventStore, Retrieved counts calls to Retrieve
+type EventStore struct {
+	Events    []model.Event
+	Retrieved int
+	Err       error
+}
+
+// Emit is ...
+// like mongo.EventMongoStore, unless multiple the event of the same user, merchant and action is
+// refreshed in place rather than appended
+func (m *EventStore) Emit(_ context.Context, e *model.Event, multiple bool) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	if !multiple {
+		for i, v := range m.Events {
+			if v.UserId == e.UserId && v.MerchantId == e.MerchantId && v.Action == e.Action {
+				m.Events[i].EventTime = e.EventTime
+				return nil
+			}
+		}
+	}
+	m.Events = append(m.Events, *e)
+	return nil
+}
+
+// FindUserMerchants is ...
+func (m *EventStore) FindUserMerchants(_ context.Context, userId string) ([]string, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	var (
+		seen = make(map[string]bool)
+		mid  []string
+	)
+	for _, e := range m.Events {
+		if e.UserId == userId && !seen[e.MerchantId] {
+			seen[e.MerchantId] = true
+			mid = append(mid, e.MerchantId)
+		}
+	}
+	return mid, nil
+}
+
+// Retrieve is ...
+func (m *EventStore) Retrieve(_ context.Context, userId, merchantId string, e []string, meta map[string]interface{}, limit, skip int64) ([]model.Event, error) {
+	m.Retrieved++
+	if m.Err != nil {
+		return nil, m.Err
+	}
+
+	// newest first by event time then by insertion, the same order as mongo.EventMongoStore
+	var events []model.Event
+	for i := len(m.Events) - 1; i >= 0; i-- {
+		v := m.Events[i]
+		if v.UserId != userId || v.MerchantId != merchantId || !containsAction(e, v.Action) || !matchesMeta(v.Meta, meta) {
+			continue
+		}
+		events = append(events, v)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].EventTime > events[j].EventTime
+	})
+
+	if skip >= int64(len(events)) {
+		return nil, nil
+	}
+	events = events[skip:]
+	if limit > 0 && limit < int64(len(events)) {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+func containsAction(actions []string, action string) bool {
+	if len(actions) == 0 {
+		return true
+	}
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesMeta(have, want map[string]interface{}) bool {
+	for k, v := range want {
+		if have[k] != v {
+			return false
+		}
+	}
+	return true
+}
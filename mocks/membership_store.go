@@ -0,0 +1,89 @@
+package mocks
+
+import (
+	model "github.com/ndv6/gate/internal/models"
+	"github.com/ndv6/gate/internal/modules/subjects"
+	"github.com/pkg/errors"
+)
+
+// MembershipStore in-memory mock of subjects.Store
+type MembershipStore struct {
+	Memberships []model.Membership
+	Err         error
+}
+
+// Insert is ...
+func (m *MembershipStore) Insert(member, group string) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	for _, v := range m.Memberships {
+		if v.Member == member && v.Group == group {
+			return nil
+		}
+	}
+	m.Memberships = append(m.Memberships, *model.NewMembership(member, group))
+	return nil
+}
+
+// Delete is ...
+func (m *MembershipStore) Delete(member, group string) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	for i, v := range m.Memberships {
+		if v.Member == member && v.Group == group {
+			m.Memberships = append(m.Memberships[:i], m.Memberships[i+1:]...)
+			return nil
+		}
+	}
+	return errors.WithStack(subjects.ErrMembershipNotFound)
+}
+
+// GroupsOf is ...
+func (m *MembershipStore) GroupsOf(members ...string) ([]string, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	var (
+		seen   = make(map[string]bool)
+		groups []string
+	)
+	for _, v := range m.Memberships {
+		if seen[v.Group] {
+			continue
+		}
+		for _, member := range members {
+			if v.Member == member {
+				seen[v.Group] = true
+				groups = append(groups, v.Group)
+				break
+			}
+		}
+	}
+	return groups, nil
+}
+
+// MembersOf is ...
+func (m *MembershipStore) MembersOf(groups ...string) ([]string, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	var (
+		seen    = make(map[string]bool)
+		members []string
+	)
+	for _, v := range m.Memberships {
+		if seen[v.Member] {
+			continue
+		}
+		for _, group := range groups {
+			if v.Group == group {
+				seen[v.Member] = true
+				members = append(members, v.Member)
+				break
+			}
+		}
+	}
+	return members, nil
+}
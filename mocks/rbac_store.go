@@ -0,0 +1,105 @@
+package mocks
+
+import (
+	"sort"
+
+	"github.com/ndv6/gate/internal/modules/rbac"
+	"github.com/pkg/errors"
+)
+
+// RBACStore in-memory mock of rbac.Store
+type RBACStore struct {
+	Roles    map[string]rbac.Role
+	Bindings map[string]rbac.RoleBinding
+	Err      error
+}
+
+// GetRole is ...
+func (m *RBACStore) GetRole(name string) (*rbac.Role, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	r, ok := m.Roles[name]
+	if !ok {
+		return nil, errors.WithStack(rbac.ErrRoleNotFound)
+	}
+	return &r, nil
+}
+
+// SaveRole is ...
+func (m *RBACStore) SaveRole(r *rbac.Role) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	if m.Roles == nil {
+		m.Roles = make(map[string]rbac.Role)
+	}
+	m.Roles[r.Name] = *r
+	return nil
+}
+
+// DeleteRole is ...
+func (m *RBACStore) DeleteRole(name string) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	if _, ok := m.Roles[name]; !ok {
+		return errors.WithStack(rbac.ErrRoleNotFound)
+	}
+	delete(m.Roles, name)
+	return nil
+}
+
+// GetBinding is ...
+func (m *RBACStore) GetBinding(id string) (*rbac.RoleBinding, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	b, ok := m.Bindings[id]
+	if !ok {
+		return nil, errors.WithStack(rbac.ErrBindingNotFound)
+	}
+	return &b, nil
+}
+
+// SaveBinding is ...
+func (m *RBACStore) SaveBinding(b *rbac.RoleBinding) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	if m.Bindings == nil {
+		m.Bindings = make(map[string]rbac.RoleBinding)
+	}
+	m.Bindings[b.ID] = *b
+	return nil
+}
+
+// DeleteBinding is ...
+func (m *RBACStore) DeleteBinding(id string) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	if _, ok := m.Bindings[id]; !ok {
+		return errors.WithStack(rbac.ErrBindingNotFound)
+	}
+	delete(m.Bindings, id)
+	return nil
+}
+
+// FindBindings is ...
+func (m *RBACStore) FindBindings(role string) ([]*rbac.RoleBinding, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	var bindings []*rbac.RoleBinding
+	for _, b := range m.Bindings {
+		if b.Role == role {
+			b := b
+			bindings = append(bindings, &b)
+		}
+	}
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].ID < bindings[j].ID
+	})
+	return bindings, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	model "github.com/ndv6/gate/internal/models"
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,9 +15,9 @@ import (
 
 // EventStore list functions
 type EventStore interface {
-	Emit(ctx context.Context, e *Event, multiple bool) error
+	Emit(ctx context.Context, e *model.Event, multiple bool) error
 	FindUserMerchants(ctx context.Context, userId string) ([]string, error)
-	Retrieve(ctx context.Context, userId, merchantId string, e []string, meta map[string]interface{}, limit, skip int64) (events []Event, err error) /**/
+	Retrieve(ctx context.Context, userId, merchantId string, e []string, meta map[string]interface{}, limit, skip int64) (events []model.Event, err error) /**/
 }
 
 // EventMongoStore is ...
@@ -30,7 +31,7 @@ func NewEventMongoStore(db *mongo.Collection) *EventMongoStore {
 }
 
 // Emit is ...
-func (store *EventMongoStore) Emit(ctx context.Context, e *Event, multiple bool) error {
+func (store *EventMongoStore) Emit(ctx context.Context, e *model.Event, multiple bool) error {
 	t := time.Now().Unix()
 	if time.Unix(e.EventTime, 0).IsZero() {
 		e.EventTime = t
@@ -87,7 +88,7 @@ func (store *EventMongoStore) FindUserMerchants(ctx context.Context, userId stri
 }
 
 // Retrieve is ...
-func (store *EventMongoStore) Retrieve(ctx context.Context, userId, merchantId string, e []string, meta map[string]interface{}, limit, skip int64) (events []Event, err error) {
+func (store *EventMongoStore) Retrieve(ctx context.Context, userId, merchantId string, e []string, meta map[string]interface{}, limit, skip int64) (events []model.Event, err error) {
 	filters := bson.A{
 		bson.M{"user_id": userId},
 		bson.M{"merchant_id": merchantId},
@@ -103,7 +104,8 @@ func (store *EventMongoStore) Retrieve(ctx context.Context, userId, merchantId s
 	filter := bson.M{
 		"$and": filters,
 	}
-	opt := options.Find().SetSkip(skip).SetLimit(limit).SetSort(bson.M{"_id": -1})
+	// upserted events keep their _id while event_time is refreshed, newest events come first by event_time
+	opt := options.Find().SetSkip(skip).SetLimit(limit).SetSort(bson.D{{"event_time", -1}, {"_id", -1}})
 
 	var c *mongo.Cursor
 	c, err = store.db.Find(ctx, filter, opt)
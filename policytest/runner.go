@@ -0,0 +1,166 @@
+package policytest
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/ndv6/gate/warden"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+// Result of a case, Trace explains how the decision was made
+type Result struct {
+	Name     string   `json:"name"`
+	Expected string   `json:"expected"`
+	Actual   string   `json:"actual"`
+	Passed   bool     `json:"passed"`
+	Error    string   `json:"error,omitempty"`
+	Trace    []string `json:"trace"`
+}
+
+// Report results of a suite
+type Report struct {
+	Results []Result `json:"results"`
+	Passed  int      `json:"passed"`
+	Failed  int      `json:"failed"`
+}
+
+// Failures returns results of failed cases
+func (r *Report) Failures() []Result {
+	var failures []Result
+	for _, res := range r.Results {
+		if !res.Passed {
+			failures = append(failures, res)
+		}
+	}
+	return failures
+}
+
+// Write prints failed cases with their trace, verbose prints passed cases as well
+func (r *Report) Write(out io.Writer, verbose bool) error {
+	for _, res := range r.Results {
+		if res.Passed && !verbose {
+			continue
+		}
+		status := "PASS"
+		if !res.Passed {
+			status = "FAIL"
+		}
+		if _, err := fmt.Fprintf(out, "%s %s: expected %s got %s\n", status, res.Name, res.Expected, res.Actual); err != nil {
+			return errors.WithStack(err)
+		}
+		if res.Error != "" {
+			if _, err := fmt.Fprintf(out, "    error: %s\n", res.Error); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		for _, line := range res.Trace {
+			if _, err := fmt.Fprintf(out, "    %s\n", line); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+	_, err := fmt.Fprintf(out, "%d passed, %d failed\n", r.Passed, r.Failed)
+	return errors.WithStack(err)
+}
+
+// Options resolvers applied while running a suite, the same as those of the warden deciding in production
+// ResourceSeparator enables hierarchical resources, see warden.Warden
+type Options struct {
+	Subjects          warden.SubjectResolver
+	Actions           warden.ActionResolver
+	ResourceSeparator string
+}
+
+// Run evaluates every case of the suite against policies of the manager
+func Run(m ladon.Manager, s *Suite) *Report {
+	return RunWith(m, s, Options{})
+}
+
+// RunWith evaluates every case of the suite against policies of the manager with given resolvers
+func RunWith(m ladon.Manager, s *Suite, opts Options) *Report {
+	w := warden.NewWarden(m, ladon.DefaultAuditLogger)
+	w.Subjects = opts.Subjects
+	w.Actions = opts.Actions
+	w.ResourceSeparator = opts.ResourceSeparator
+	return RunWarden(w, s)
+}
+
+// RunWarden evaluates every case of the suite with a traced dry run copy of the warden, decisions are not audited
+// and conditions with side effects are assumed to pass without running
+func RunWarden(w *warden.Warden, s *Suite) *Report {
+	c := *w
+	c.Trace = true
+	c.DryRun = true
+	c.AuditLogger = ladon.DefaultAuditLogger
+	w = &c
+
+	report := &Report{Results: make([]Result, 0, len(s.Cases))}
+	for _, c := range s.Cases {
+		r := c.Request
+		res := Result{Name: c.Name, Expected: c.Expect}
+
+		d, err := w.Decide(&r)
+		if err != nil {
+			res.Actual, res.Error = "error", err.Error()
+		} else {
+			res.Actual, res.Trace = d.Outcome(), trace(d)
+			res.Passed = res.Actual == c.Expect || (c.Expect == ExpectDeny && res.Actual != ExpectAllow)
+		}
+
+		if res.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Results = append(report.Results, res)
+	}
+	return report
+}
+
+// AssertSuite runs the suite and reports every failed case to t along with its trace
+func AssertSuite(t testing.TB, m ladon.Manager, s *Suite) {
+	t.Helper()
+	AssertSuiteWith(t, m, s, Options{})
+}
+
+// AssertSuiteWith is like AssertSuite with given resolvers
+func AssertSuiteWith(t testing.TB, m ladon.Manager, s *Suite, opts Options) {
+	t.Helper()
+	for _, res := range RunWith(m, s, opts).Failures() {
+		t.Errorf("%s: expected %s got %s %s\n%v", res.Name, res.Expected, res.Actual, res.Error, res.Trace)
+	}
+}
+
+// trace renders the decision into readable lines
+func trace(d *warden.Decision) []string {
+	var lines []string
+	if len(d.Subjects) > 1 {
+		lines = append(lines, fmt.Sprintf("subjects %v", d.Subjects))
+	}
+	if len(d.Actions) > 1 {
+		lines = append(lines, fmt.Sprintf("actions %v", d.Actions))
+	}
+	if len(d.Resources) > 1 {
+		lines = append(lines, fmt.Sprintf("resources %v", d.Resources))
+	}
+	if len(d.Trace) == 0 {
+		lines = append(lines, "no policy applies to the request")
+	}
+	for _, t := range d.Trace {
+		if t.Applied {
+			lines = append(lines, fmt.Sprintf("policy %s (%s) applied", t.Policy, t.Effect))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("policy %s (%s) skipped: %s", t.Policy, t.Effect, t.Reason))
+	}
+	for _, c := range d.Conditions {
+		lines = append(lines, fmt.Sprintf("condition %s of policy %s (%s) passed=%v %v", c.Key, c.Policy, c.Type, c.Passed, c.Details))
+	}
+	if d.ExplicitDeny {
+		lines = append(lines, fmt.Sprintf("explicitly denied by %v", d.Deciders))
+	}
+	return lines
+}
@@ -0,0 +1,133 @@
+package policytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ndv6/gate/warden"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	// registers conditions shipped with gate so policies files can use them
+	_ "github.com/ndv6/gate/internal/modules/conditions"
+)
+
+const (
+	// ExpectAllow case expects the request to be allowed
+	ExpectAllow = warden.OutcomeAllow
+
+	// ExpectDeny case expects the request to be refused for any reason
+	ExpectDeny = warden.OutcomeDeny
+
+	// ExpectStepUp case expects the request to require step-up authentication
+	ExpectStepUp = warden.OutcomeStepUp
+)
+
+// Case request along with its expected outcome
+type Case struct {
+	Name    string        `json:"name"`
+	Request ladon.Request `json:"request"`
+	Expect  string        `json:"expect"`
+}
+
+// Suite list of cases kept next to policies
+type Suite struct {
+	Cases []Case `json:"cases"`
+}
+
+// Validate checks expectations of every case
+func (s *Suite) Validate() error {
+	for i, c := range s.Cases {
+		switch c.Expect {
+		case ExpectAllow, ExpectDeny, ExpectStepUp:
+		default:
+			return errors.Errorf("case #%d (%s) expects unknown outcome %q", i, c.Name, c.Expect)
+		}
+	}
+	return nil
+}
+
+// ParseSuite decodes a YAML or JSON suite
+func ParseSuite(data []byte) (*Suite, error) {
+	var s Suite
+	if err := decode(data, &s); err != nil {
+		return nil, errors.Wrap(err, "failed decoding suite")
+	}
+	for i := range s.Cases {
+		if s.Cases[i].Name == "" {
+			s.Cases[i].Name = fmt.Sprintf("#%d", i)
+		}
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// LoadSuite reads a YAML or JSON suite file
+func LoadSuite(path string) (*Suite, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ParseSuite(data)
+}
+
+// ParsePolicies decodes a YAML or JSON list of policies into an in-memory manager
+func ParsePolicies(data []byte) (ladon.Manager, error) {
+	var ps []*ladon.DefaultPolicy
+	if err := decode(data, &ps); err != nil {
+		return nil, errors.Wrap(err, "failed decoding policies")
+	}
+
+	m := memory.NewMemoryManager()
+	for _, p := range ps {
+		if err := m.Create(p); err != nil {
+			return nil, errors.Wrapf(err, "failed loading policy %s", p.ID)
+		}
+	}
+	return m, nil
+}
+
+// LoadPolicies reads a YAML or JSON file of policies into an in-memory manager
+func LoadPolicies(path string) (ladon.Manager, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ParsePolicies(data)
+}
+
+// decode reads YAML, JSON being a subset of it, and decodes it through JSON so
+// json tags and custom unmarshalers i.e. of ladon conditions apply
+func decode(data []byte, v interface{}) error {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return errors.WithStack(err)
+	}
+	out, err := json.Marshal(normalize(raw))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(json.Unmarshal(out, v))
+}
+
+// normalize turns maps decoded from YAML into maps JSON can encode
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = normalize(val)
+		}
+		return m
+	case []interface{}:
+		for i := range t {
+			t[i] = normalize(t[i])
+		}
+	}
+	return v
+}
@@ -0,0 +1,43 @@
+package registry
+
+// Describer is implemented by conditions providing a human readable description
+type Describer interface {
+	Description() string
+}
+
+// descriptions of conditions shipped with ladon
+var builtinDescriptions = map[string]string{
+	"StringEqualCondition":      "Given value equals a string",
+	"CIDRCondition":             "Given IP address is within a CIDR range",
+	"EqualsSubjectCondition":    "Given value equals the request subject",
+	"StringPairsEqualCondition": "Given value is a list of pairs whose both elements are equal",
+	"StringMatchCondition":      "Given value matches a regular expression",
+	"ResourceContainsCondition": "Request resource contains given value",
+	"BooleanCondition":          "Given value equals a boolean",
+}
+
+// Entry describes a registered condition type
+type Entry struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Schema      Schema `json:"schema"`
+}
+
+// Catalog lists every registered condition with its description and options schema, ordered by name
+func Catalog() []Entry {
+	names := List()
+	entries := make([]Entry, 0, len(names))
+	for _, name := range names {
+		c, err := Lookup(name)
+		if err != nil {
+			continue
+		}
+
+		e := Entry{Name: name, Description: builtinDescriptions[name], Schema: SchemaOf(c)}
+		if d, ok := c.(Describer); ok {
+			e.Description = d.Description()
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
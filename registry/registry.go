@@ -0,0 +1,102 @@
+// Package registry keeps track of condition types policies can be built of, applications embedding
+// GateOne register their own conditions here; those implementing `Validate() error` are validated
+// whenever a policy using them is stored
+//
+// registration is meant for init functions only: ladon reads ladon.ConditionFactories without locking
+// while decoding policies, so registering once policies are being decoded is a data race
+package registry
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+)
+
+// Factory creates a new, empty condition
+type Factory func() ladon.Condition
+
+var (
+	// ErrConditionExists is returned when a condition name is already registered
+	ErrConditionExists = errors.New("condition type already registered")
+
+	// ErrConditionNotFound is returned when no condition is registered under given name
+	ErrConditionNotFound = errors.New("condition type not registered")
+
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+func init() {
+	// conditions shipped with ladon are available from the start
+	for name, f := range ladon.ConditionFactories {
+		factories[name] = f
+	}
+}
+
+// Register adds a condition type under the name returned by its GetName
+// the factory is registered to ladon.ConditionFactories as well so JSON decoding keeps working,
+// it must only be called from init functions, before any policy is decoded
+func Register(f Factory) error {
+	if f == nil {
+		return errors.New("condition factory can not be nil")
+	}
+	c := f()
+	if c == nil {
+		return errors.New("condition factory returned nil")
+	}
+	name := c.GetName()
+	if name == "" {
+		return errors.New("condition name can not be empty")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, found := factories[name]; found {
+		return errors.Wrapf(ErrConditionExists, "condition %s", name)
+	}
+	factories[name] = f
+	ladon.ConditionFactories[name] = func() ladon.Condition {
+		return f()
+	}
+	return nil
+}
+
+// MustRegister is like Register but panics on error
+func MustRegister(f Factory) {
+	if err := Register(f); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup creates a new condition of given type
+func Lookup(name string) (ladon.Condition, error) {
+	mu.RLock()
+	f, found := factories[name]
+	mu.RUnlock()
+	if !found {
+		return nil, errors.Wrapf(ErrConditionNotFound, "Could not find condition type %s", name)
+	}
+	return f(), nil
+}
+
+// Exists reports whether a condition type is registered
+func Exists(name string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	_, found := factories[name]
+	return found
+}
+
+// List returns names of every registered condition type in alphabetical order
+func List() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
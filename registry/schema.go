@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/ory/ladon"
+)
+
+var conditionType = reflect.TypeOf((*ladon.Condition)(nil)).Elem()
+
+// Schema JSON Schema document
+type Schema map[string]interface{}
+
+// SchemaOf derives the JSON Schema of a condition options from its struct and json tags
+// fields tagged with `omitempty` or with a `default` tag are optional, a `description` tag documents the field
+// defaults of string fields are taken as is, others as JSON i.e. `default:":"` and `default:"[]"`
+func SchemaOf(c ladon.Condition) Schema {
+	s := schemaOf(reflect.TypeOf(c), make(map[reflect.Type]bool))
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = c.GetName()
+	return s
+}
+
+func schemaOf(t reflect.Type, seen map[reflect.Type]bool) Schema {
+	if t.Implements(conditionType) && t.Kind() == reflect.Interface {
+		return envelopeSchema()
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": schemaOf(t.Elem(), seen)}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": schemaOf(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return Schema{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		return structSchema(t, seen)
+	}
+
+	// interfaces accept any value
+	return Schema{}
+}
+
+func structSchema(t reflect.Type, seen map[reflect.Type]bool) Schema {
+	var (
+		properties = make(map[string]interface{})
+		required   = make([]string, 0)
+	)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name, opts := f.Name, ""
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			parts := strings.SplitN(tag, ",", 2)
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			if len(parts) > 1 {
+				opts = parts[1]
+			}
+		}
+
+		p := schemaOf(f.Type, seen)
+		if d, ok := f.Tag.Lookup("description"); ok {
+			p["description"] = d
+		}
+		d, defaulted := f.Tag.Lookup("default")
+		if defaulted {
+			p["default"] = defaultValue(f.Type, d)
+		}
+		properties[name] = p
+		if !defaulted && !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	s := Schema{"type": "object", "properties": properties, "additionalProperties": false}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func defaultValue(t reflect.Type, d string) interface{} {
+	if t.Kind() == reflect.String {
+		return d
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(d), &v); err != nil {
+		return d
+	}
+	return v
+}
+
+// envelopeSchema nested conditions are stored with their type and options
+func envelopeSchema() Schema {
+	return Schema{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"type":    Schema{"type": "string", "description": "registered condition name"},
+			"options": Schema{"type": "object"},
+		},
+		"required": []string{"type"},
+	}
+}
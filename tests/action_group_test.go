@@ -0,0 +1,104 @@
+package gate_test
+
+import (
+	"github.com/ndv6/gate/internal/modules/actions"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ndv6/gate/mocks"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestActionGroups(t *testing.T) {
+	groups := actions.NewResolver(&mocks.ActionGroupStore{})
+	for _, g := range []*actions.Group{
+		{Name: "write", Actions: []string{"create", "update", "delete"}},
+		{Name: "read", Actions: []string{"get", "list"}},
+		{Name: "manage", Actions: []string{"write", "read"}},
+		{Name: "edit", Actions: []string{"update"}},
+	} {
+		if err := groups.Define(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("ActionGroup_Expand", func(t *testing.T) {
+		expanded, err := groups.Expand("update")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"update", "edit", "write", "manage"}
+		if len(expanded) != len(expected) {
+			t.Fatalf("expected %v got %v", expected, expanded)
+		}
+		for i := range expected {
+			if expanded[i] != expected[i] {
+				t.Errorf("expected %v got %v", expected, expanded)
+			}
+		}
+	})
+
+	t.Run("ActionGroup_Cycle", func(t *testing.T) {
+		for _, g := range []*actions.Group{
+			{Name: "write", Actions: []string{"create", "manage"}},
+			{Name: "read", Actions: []string{"read"}},
+		} {
+			if err := groups.Define(g); errors.Cause(err) != actions.ErrGroupCycle {
+				t.Errorf("expected %v got %v", actions.ErrGroupCycle, err)
+			}
+		}
+	})
+
+	t.Run("ActionGroup_Decide", func(t *testing.T) {
+		mp := memory.NewMemoryManager()
+		policies := []*ladon.DefaultPolicy{
+			{
+				ID:        "staff-write-room",
+				Subjects:  []string{"groups:staff"},
+				Effect:    ladon.AllowAccess,
+				Resources: []string{"room:<.*>"},
+				Actions:   []string{"write"},
+			},
+			{
+				ID:        "admins-manage-room",
+				Subjects:  []string{"groups:administrators"},
+				Effect:    ladon.AllowAccess,
+				Resources: []string{"room:<.*>"},
+				Actions:   []string{"manage"},
+			},
+		}
+		for _, p := range policies {
+			if err := mp.Create(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		w := warden.NewWarden(mp, ladon.DefaultAuditLogger)
+		w.Actions = groups
+
+		type Pair struct {
+			result  bool
+			request ladon.Request
+		}
+
+		var payloads = []Pair{
+			{true, ladon.Request{Subject: "groups:staff", Resource: "room:5", Action: "delete"}},
+			{false, ladon.Request{Subject: "groups:staff", Resource: "room:5", Action: "get"}},
+			{true, ladon.Request{Subject: "groups:administrators", Resource: "room:5", Action: "get"}},
+			{true, ladon.Request{Subject: "groups:administrators", Resource: "room:5", Action: "update"}},
+			{false, ladon.Request{Subject: "groups:administrators", Resource: "room:5", Action: "export"}},
+		}
+		for i, p := range payloads {
+			d, err := w.Decide(&p.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Allowed != p.result {
+				t.Errorf("#%d: expected %v got %v", i, p.result, d.Allowed)
+			}
+			if len(d.Actions) == 0 || d.Actions[0] != p.request.Action {
+				t.Errorf("#%d: expected actions to start with %s got %v", i, p.request.Action, d.Actions)
+			}
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package gate_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ndv6/gate/api"
+	"github.com/ndv6/gate/warden"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeMux(t *testing.T) {
+	mp := memory.NewMemoryManager()
+	if err := mp.Create(&ladon.DefaultPolicy{
+		ID:        "staff-rooms",
+		Subjects:  []string{"groups:staff"},
+		Effect:    ladon.AllowAccess,
+		Resources: []string{"room:<.*>"},
+		Actions:   []string{"get"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	w := warden.NewWarden(mp, nil)
+
+	srv := httptest.NewServer(api.NewServeMux(api.Handlers{
+		Authenticate: api.BearerToken("secret"),
+		Wardens:      func(merchant string) (*warden.Warden, error) { return w, nil },
+	}))
+	defer srv.Close()
+
+	request := func(method, path, token string, body []byte) *http.Request {
+		r, err := http.NewRequest(method, srv.URL+path, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		return r
+	}
+	permissions := func(merchant string) []byte {
+		body, _ := json.Marshal(map[string]interface{}{"merchant": merchant, "subject": "groups:staff"})
+		return body
+	}
+
+	type Pair struct {
+		status  int
+		request *http.Request
+	}
+
+	var payloads = []Pair{
+		{http.StatusOK, request(http.MethodPost, "/permissions", "secret", permissions("eliving"))},
+		{http.StatusOK, request(http.MethodGet, "/conditions", "", nil)},
+		{http.StatusOK, request(http.MethodGet, "/grantees?merchant=eliving&resource=room:5&action=get", "secret", nil)},
+		{http.StatusOK, request(http.MethodGet, "/lint?merchant=eliving", "secret", nil)},
+		{http.StatusNotFound, request(http.MethodGet, "/decisions?merchant=eliving", "secret", nil)},
+		{http.StatusUnauthorized, request(http.MethodPost, "/permissions", "", permissions("eliving"))},
+		{http.StatusUnauthorized, request(http.MethodPost, "/permissions", "wrong", permissions("eliving"))},
+		{http.StatusUnauthorized, request(http.MethodGet, "/grantees?merchant=eliving&resource=room:5&action=get", "", nil)},
+		{http.StatusUnauthorized, request(http.MethodGet, "/lint?merchant=eliving", "", nil)},
+		{http.StatusBadRequest, request(http.MethodPost, "/permissions", "secret", permissions("eliving.x"))},
+		{http.StatusBadRequest, request(http.MethodGet, "/grantees?merchant=eliving%24&resource=room:5&action=get", "secret", nil)},
+		{http.StatusBadRequest, request(http.MethodGet, "/lint?merchant=../eliving", "secret", nil)},
+	}
+	for _, p := range payloads {
+		res, err := http.DefaultClient.Do(p.request)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != p.status {
+			t.Errorf("%s %s: expected %d got %d", p.request.Method, p.request.URL, p.status, res.StatusCode)
+		}
+	}
+
+	t.Run("ServeMux_NoAuthenticator", func(t *testing.T) {
+		srv := httptest.NewServer(api.NewServeMux(api.Handlers{
+			Wardens: func(merchant string) (*warden.Warden, error) { return w, nil },
+		}))
+		defer srv.Close()
+
+		r, err := http.NewRequest(http.MethodGet, srv.URL+"/lint?merchant=eliving", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Authorization", "Bearer ")
+		res, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected %v got %v", http.StatusUnauthorized, res.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package gate_test
+
+import (
+	"encoding/json"
+	"github.com/ndv6/gate/api"
+	"github.com/ndv6/gate/internal/modules/audit"
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ndv6/gate/mocks"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuditLogger(t *testing.T) {
+	mp := memory.NewMemoryManager()
+	for _, p := range []*ladon.DefaultPolicy{
+		{
+			ID:        "staff-rooms",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"room:<.*>"},
+			Actions:   []string{"get"},
+		},
+		{
+			ID:         "staff-floor",
+			Subjects:   []string{"groups:staff"},
+			Effect:     ladon.AllowAccess,
+			Resources:  []string{"floor:1"},
+			Actions:    []string{"get"},
+			Conditions: ladon.Conditions{"code": &ladon.StringEqualCondition{Equals: "1234"}},
+		},
+	} {
+		if err := mp.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sink := &mocks.AuditSink{}
+	logger := audit.NewAuditLogger("eliving", sink, audit.Options{BatchSize: 2, FlushInterval: time.Hour})
+	w := warden.NewWarden(mp, logger)
+
+	type Pair struct {
+		request  *ladon.Request
+		expected audit.Entry
+	}
+
+	var payloads = []Pair{
+		{
+			&ladon.Request{Subject: "groups:staff", Resource: "room:5", Action: "get", Context: ladon.Context{audit.CallerKey: "booking"}},
+			audit.Entry{Caller: "booking", Outcome: warden.OutcomeAllow, Allowed: true, Deciders: []string{"staff-rooms"}, Matched: []string{"staff-rooms"}},
+		},
+		{
+			&ladon.Request{Subject: "groups:staff", Resource: "floor:1", Action: "get"},
+			audit.Entry{Outcome: warden.OutcomeDeny, Matched: []string{"staff-floor"}},
+		},
+		{
+			&ladon.Request{Subject: "groups:guests", Resource: "room:5", Action: "get"},
+			audit.Entry{Outcome: warden.OutcomeDeny},
+		},
+	}
+	for _, p := range payloads {
+		if _, err := w.Decide(p.request); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger.Close()
+
+	if stats := logger.Stats(); stats.Written != 3 || stats.Dropped != 0 || stats.Failed != 0 {
+		t.Errorf("expected 3 written got %+v", stats)
+	}
+	if sink.Batches != 2 {
+		t.Errorf("expected %v got %v", 2, sink.Batches)
+	}
+	if len(sink.Entries) != len(payloads) {
+		t.Fatalf("expected %d entries got %+v", len(payloads), sink.Entries)
+	}
+	for i, p := range payloads {
+		got := sink.Entries[i]
+		if got.ID == "" || got.Merchant != "eliving" || got.Request.Resource != p.request.Resource || got.CreatedAt == 0 ||
+			got.Caller != p.expected.Caller || got.Outcome != p.expected.Outcome || got.Allowed != p.expected.Allowed ||
+			len(got.Deciders) != len(p.expected.Deciders) || len(got.Matched) != len(p.expected.Matched) {
+			t.Errorf("#%d: expected %+v got %+v", i, p.expected, got)
+		}
+	}
+
+	t.Run("AuditLogger_Backpressure", func(t *testing.T) {
+		blocked := &mocks.AuditSink{Block: make(chan struct{})}
+		logger := audit.NewAuditLogger("eliving", blocked, audit.Options{BufferSize: 1, BatchSize: 1, BlockTimeout: time.Millisecond})
+
+		r := &ladon.Request{Subject: "groups:staff", Resource: "room:5", Action: "get"}
+		for i := 0; i < 5; i++ {
+			logger.LogDecision(r, &warden.Decision{Allowed: true}, time.Millisecond)
+		}
+		if stats := logger.Stats(); stats.Dropped < 3 {
+			t.Errorf("expected at least 3 dropped got %+v", stats)
+		}
+
+		close(blocked.Block)
+		logger.Close()
+		if stats := logger.Stats(); stats.Written+stats.Dropped != 5 {
+			t.Errorf("expected 5 written or dropped got %+v", stats)
+		}
+
+		logger.LogDecision(r, &warden.Decision{}, time.Millisecond)
+		if stats := logger.Stats(); stats.Written+stats.Dropped != 6 {
+			t.Errorf("expected decisions after close to be dropped got %+v", stats)
+		}
+	})
+
+	t.Run("AuditLogger_ContextCopied", func(t *testing.T) {
+		blocked := &mocks.AuditSink{Block: make(chan struct{})}
+		logger := audit.NewAuditLogger("eliving", blocked, audit.Options{BatchSize: 1})
+
+		r := &ladon.Request{Subject: "groups:staff", Resource: "room:5", Action: "get",
+			Context: ladon.Context{audit.CallerKey: "booking", "room": map[string]interface{}{"floor": "1"}}}
+		logger.LogDecision(r, &warden.Decision{Allowed: true}, time.Millisecond)
+		r.Context[audit.CallerKey] = "payment"
+		r.Context["room"].(map[string]interface{})["floor"] = "2"
+		logger.LogDecision(&ladon.Request{Context: ladon.Context{"c": make(chan int)}}, &warden.Decision{}, time.Millisecond)
+
+		close(blocked.Block)
+		logger.Close()
+		if stats := logger.Stats(); stats.Written != 1 || stats.Failed != 1 {
+			t.Errorf("expected 1 written and 1 failed got %+v", stats)
+		}
+		if len(blocked.Entries) != 1 {
+			t.Fatalf("expected 1 entry got %+v", blocked.Entries)
+		}
+		got := blocked.Entries[0].Request.Context
+		if got[audit.CallerKey] != "booking" || got["room"].(map[string]interface{})["floor"] != "1" {
+			t.Errorf("expected context as decided got %+v", got)
+		}
+	})
+
+	t.Run("AuditLogger_ContextReplayed", func(t *testing.T) {
+		fresh := memory.NewMemoryManager()
+		if err := fresh.Create(&ladon.DefaultPolicy{
+			ID:        "fresh-payouts",
+			Subjects:  []string{"users:<.*>"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"payout:account"},
+			Actions:   []string{"update"},
+			Conditions: ladon.Conditions{
+				"auth":    &conditions.AuthFreshness{MaxAge: 300, Methods: []string{"otp"}},
+				"ip":      &conditions.Network{Allow: []string{"10.0.0.0/8"}, TrustedProxies: 1},
+				"channel": &conditions.StringList{Field: "context.client.channels", Options: []string{"app"}},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		logged := &mocks.AuditSink{}
+		logger := audit.NewAuditLogger("eliving", logged, audit.Options{BatchSize: 1})
+		w := warden.NewWarden(fresh, logger)
+		r := &ladon.Request{Subject: "users:1", Resource: "payout:account", Action: "update", Context: ladon.Context{
+			"auth_time": int(time.Now().Unix()),
+			"amr":       []string{"pwd", "otp"},
+			"ip":        []string{"10.0.0.7", "172.16.0.1"},
+			"client":    map[string]interface{}{"channels": []string{"app"}},
+		}}
+		if err := w.IsAllowed(r); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		logger.Close()
+		if len(logged.Entries) != 1 {
+			t.Fatalf("expected 1 entry got %+v", logged.Entries)
+		}
+
+		raw, err := bson.Marshal(logged.Entries[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stored audit.Entry
+		if err := bson.Unmarshal(raw, &stored); err != nil {
+			t.Fatal(err)
+		}
+
+		for name, e := range map[string]audit.Entry{"logged": logged.Entries[0], "stored": stored} {
+			replayed := e.Request
+			if err := warden.NewWarden(fresh, nil).IsAllowed(&replayed); err != nil {
+				t.Errorf("%s: expected %v got %v", name, nil, err)
+			}
+		}
+	})
+
+	t.Run("AuditLogger_SinkFailure", func(t *testing.T) {
+		failing := &mocks.AuditSink{Err: errors.New("unavailable")}
+		logger := audit.NewAuditLogger("eliving", failing, audit.Options{})
+		w := warden.NewWarden(mp, logger)
+		if _, err := w.Decide(payloads[0].request); err != nil {
+			t.Fatal(err)
+		}
+		logger.Close()
+		if stats := logger.Stats(); stats.Failed != 1 || stats.Written != 0 {
+			t.Errorf("expected 1 failed got %+v", stats)
+		}
+	})
+
+	t.Run("AuditLogger_API", func(t *testing.T) {
+		srv := httptest.NewServer(api.Decisions(func(merchant string) (audit.Querier, error) {
+			if merchant != "eliving" {
+				return nil, errors.New("unknown merchant")
+			}
+			return sink, nil
+		}))
+		defer srv.Close()
+
+		type Query struct {
+			query    string
+			status   int
+			expected int
+		}
+
+		var queries = []Query{
+			{"?merchant=eliving", http.StatusOK, 3},
+			{"?merchant=eliving&subject=groups:staff", http.StatusOK, 2},
+			{"?merchant=eliving&resource=room:5&decision=deny", http.StatusOK, 1},
+			{"?merchant=eliving&decision=allow&from=1&limit=5", http.StatusOK, 1},
+			{"?merchant=eliving&to=1", http.StatusOK, 0},
+			{"?merchant=eliving&decision=maybe", http.StatusBadRequest, 0},
+			{"?merchant=eliving&limit=x", http.StatusBadRequest, 0},
+			{"?merchant=eliving&limit=1000000000", http.StatusBadRequest, 0},
+			{"?merchant=other", http.StatusNotFound, 0},
+		}
+		for _, q := range queries {
+			res, err := http.Get(srv.URL + q.query)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			var entries []audit.Entry
+			if res.StatusCode == http.StatusOK {
+				if err := json.NewDecoder(res.Body).Decode(&entries); err != nil {
+					t.Fatalf("%+v", err)
+				}
+			}
+			res.Body.Close()
+			if res.StatusCode != q.status || len(entries) != q.expected {
+				t.Errorf("%s: expected %d entries (%d) got %d (%d)", q.query, q.expected, q.status, len(entries), res.StatusCode)
+			}
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package gate_test
+
+import (
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"github.com/pkg/errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAuthFreshnessCondition(t *testing.T) {
+	mp := memory.NewMemoryManager()
+	policies := []*ladon.DefaultPolicy{
+		{
+			ID:        "payout-account-change",
+			Subjects:  []string{"users:<.*>"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"payout:account"},
+			Actions:   []string{"update"},
+			Conditions: ladon.Conditions{
+				"auth": &conditions.AuthFreshness{MaxAge: 300, Methods: []string{"otp", "hwk"}, AnyMethod: true},
+			},
+		},
+		{
+			ID:        "payout-account-read",
+			Subjects:  []string{"users:<.*>"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"payout:account"},
+			Actions:   []string{"get"},
+			Conditions: ladon.Conditions{
+				"auth": &conditions.AuthFreshness{MaxAge: 300},
+				"ip":   &conditions.Network{Allow: []string{"10.0.0.0/8"}},
+			},
+		},
+	}
+	for _, p := range policies {
+		if err := mp.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := warden.NewWarden(mp, ladon.DefaultAuditLogger)
+
+	var (
+		now   = time.Now().Unix()
+		stale = now - 3600
+	)
+
+	type Pair struct {
+		err     error
+		request ladon.Request
+	}
+
+	var payloads = []Pair{
+		{nil, ladon.Request{Subject: "users:1", Resource: "payout:account", Action: "update",
+			Context: ladon.Context{"auth_time": now, "amr": []string{"pwd", "otp"}}}},
+		{warden.ErrStepUpRequired, ladon.Request{Subject: "users:1", Resource: "payout:account", Action: "update",
+			Context: ladon.Context{"auth_time": stale, "amr": []string{"pwd", "otp"}}}},
+		{warden.ErrStepUpRequired, ladon.Request{Subject: "users:1", Resource: "payout:account", Action: "update",
+			Context: ladon.Context{"auth_time": now, "amr": []interface{}{"pwd"}}}},
+		// network condition fails as well, authenticating again would not help
+		{ladon.ErrRequestDenied, ladon.Request{Subject: "users:1", Resource: "payout:account", Action: "get",
+			Context: ladon.Context{"auth_time": stale, "ip": "8.8.8.8"}}},
+		{ladon.ErrRequestDenied, ladon.Request{Subject: "users:1", Resource: "payout:account", Action: "delete",
+			Context: ladon.Context{"auth_time": now}}},
+	}
+	for i, p := range payloads {
+		err := w.IsAllowed(&p.request)
+		if errors.Cause(err) != p.err {
+			t.Errorf("#%d: expected %v got %v", i, p.err, err)
+		}
+	}
+
+	t.Run("AuthFreshness_Decision", func(t *testing.T) {
+		d, err := w.Decide(&payloads[1].request)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if d.Allowed || !d.StepUpRequired {
+			t.Errorf("expected step-up decision got %+v", d)
+		}
+	})
+
+	t.Run("AuthFreshness_DecisionTime", func(t *testing.T) {
+		// a past decision is taken again at the time it was taken
+		past := warden.NewWarden(mp, nil)
+		past.Now = func() time.Time { return time.Unix(stale+60, 0) }
+		if err := past.IsAllowed(&payloads[1].request); err != nil {
+			t.Errorf("expected %v got %v", nil, err)
+		}
+
+		// the decision time given by the caller is replaced
+		spoofed := payloads[1].request
+		spoofed.Context = ladon.Context{"auth_time": stale, "amr": []string{"otp"}, warden.DecisionTimeKey: stale + 60}
+		if err := w.IsAllowed(&spoofed); errors.Cause(err) != warden.ErrStepUpRequired {
+			t.Errorf("expected %v got %v", warden.ErrStepUpRequired, err)
+		}
+	})
+
+	t.Run("AuthFreshness_Concurrent", func(t *testing.T) {
+		// defaults of a shared warden are resolved without writing them back
+		shared := &warden.Warden{Manager: mp}
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r := payloads[0].request
+				if err := shared.IsAllowed(&r); err != nil {
+					t.Errorf("expected %v got %v", nil, err)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
@@ -0,0 +1,76 @@
+package gate_test
+
+import (
+	"encoding/json"
+	"github.com/ndv6/gate/api"
+	_ "github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/registry"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConditionCatalog(t *testing.T) {
+	srv := httptest.NewServer(api.ConditionCatalog())
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer res.Body.Close()
+
+	var catalog []registry.Entry
+	if err := json.NewDecoder(res.Body).Decode(&catalog); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if len(catalog) != len(registry.List()) {
+		t.Errorf("expected %d got %d", len(registry.List()), len(catalog))
+	}
+
+	t.Run("Catalog_Schema", func(t *testing.T) {
+		res, err := http.Get(srv.URL + "?name=StringPrefixCondition")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		defer res.Body.Close()
+
+		var e struct {
+			Description string `json:"description"`
+			Schema      struct {
+				Properties map[string]struct {
+					Type    string      `json:"type"`
+					Default interface{} `json:"default"`
+				} `json:"properties"`
+				Required []string `json:"required"`
+			} `json:"schema"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if e.Description == "" {
+			t.Error("expected description")
+		}
+		if e.Schema.Properties["prefix"].Type != "string" || e.Schema.Properties["case_sensitive"].Type != "boolean" {
+			t.Errorf("unexpected properties %+v", e.Schema.Properties)
+		}
+		// case_sensitive defaults to false and field is omitted when empty
+		if len(e.Schema.Required) != 1 || e.Schema.Required[0] != "prefix" {
+			t.Errorf("expected optional fields to be left out of %v", e.Schema.Required)
+		}
+		if e.Schema.Properties["case_sensitive"].Default != false {
+			t.Errorf("expected %v got %v", false, e.Schema.Properties["case_sensitive"].Default)
+		}
+	})
+
+	t.Run("Catalog_NotFound", func(t *testing.T) {
+		res, err := http.Get(srv.URL + "?name=UnknownCondition")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("expected %d got %d", http.StatusNotFound, res.StatusCode)
+		}
+	})
+}
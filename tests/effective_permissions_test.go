@@ -0,0 +1,182 @@
+package gate_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ndv6/gate/api"
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/internal/modules/subjects"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ndv6/gate/mocks"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEffectivePermissions(t *testing.T) {
+	mp := memory.NewMemoryManager()
+	for _, p := range []*ladon.DefaultPolicy{
+		{
+			ID:        "staff-rooms",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"room:<.*>"},
+			Actions:   []string{"get", "update"},
+		},
+		{
+			ID:        "staff-no-room-5-update",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.DenyAccess,
+			Resources: []string{"room:5"},
+			Actions:   []string{"update"},
+		},
+		{
+			ID:         "staff-floor",
+			Subjects:   []string{"groups:staff"},
+			Effect:     ladon.AllowAccess,
+			Resources:  []string{"floor:1"},
+			Actions:    []string{"open"},
+			Conditions: ladon.Conditions{"code": &ladon.StringEqualCondition{Equals: "1234"}},
+		},
+		{
+			ID:        "users-vault",
+			Subjects:  []string{"users:<.*>"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"vault:1"},
+			Actions:   []string{"get"},
+		},
+		{
+			ID:        "staff-no-vault",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.DenyAccess,
+			Resources: []string{"vault:<.*>"},
+			Actions:   []string{"get"},
+		},
+		{
+			ID:        "admins-everything",
+			Subjects:  []string{"groups:administrators"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"<.*>"},
+			Actions:   []string{"<.*>"},
+		},
+	} {
+		if err := mp.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := subjects.NewHierarchy(&mocks.MembershipStore{})
+	if err := h.Add("users:42", "groups:staff"); err != nil {
+		t.Fatal(err)
+	}
+	w := warden.NewWarden(mp, ladon.DefaultAuditLogger)
+	w.Subjects = h
+
+	type Pair struct {
+		context  ladon.Context
+		expected []warden.Permission
+	}
+
+	var payloads = []Pair{
+		{nil, []warden.Permission{
+			{Resource: "floor:1", Action: "open", Conditional: true},
+			{Resource: "room:<.*>", Action: "get"},
+			{Resource: "room:<.*>", Action: "update", Restrictions: []string{"staff-no-room-5-update"}},
+		}},
+		{ladon.Context{"code": "1234"}, []warden.Permission{
+			{Resource: "floor:1", Action: "open"},
+			{Resource: "room:<.*>", Action: "get"},
+			{Resource: "room:<.*>", Action: "update", Restrictions: []string{"staff-no-room-5-update"}},
+		}},
+		{ladon.Context{"code": "0000"}, []warden.Permission{
+			{Resource: "room:<.*>", Action: "get"},
+			{Resource: "room:<.*>", Action: "update", Restrictions: []string{"staff-no-room-5-update"}},
+		}},
+	}
+	for i, p := range payloads {
+		permissions, err := w.EffectivePermissions("users:42", p.context)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(permissions) != len(p.expected) {
+			t.Fatalf("#%d: expected %d permissions got %+v", i, len(p.expected), permissions)
+		}
+		for j, e := range p.expected {
+			got := permissions[j]
+			if got.Resource != e.Resource || got.Action != e.Action || got.Conditional != e.Conditional ||
+				len(got.Restrictions) != len(e.Restrictions) {
+				t.Errorf("#%d: expected %+v got %+v", i, e, got)
+			}
+		}
+	}
+
+	t.Run("Permissions_SideEffects", func(t *testing.T) {
+		var hits int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits++
+			w.Write([]byte("true"))
+		}))
+		defer srv.Close()
+
+		mp := memory.NewMemoryManager()
+		if err := mp.Create(&ladon.DefaultPolicy{
+			ID:         "users-payout",
+			Subjects:   []string{"users:<.*>"},
+			Effect:     ladon.AllowAccess,
+			Resources:  []string{"payout"},
+			Actions:    []string{"create"},
+			Conditions: ladon.Conditions{"kyc": &conditions.Webhook{URL: srv.URL}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		permissions, err := warden.NewWarden(mp, ladon.DefaultAuditLogger).EffectivePermissions("users:42", ladon.Context{"kyc": "eliving"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(permissions) != 1 || !permissions[0].Conditional {
+			t.Errorf("expected a conditional permission got %+v", permissions)
+		}
+		if hits != 0 {
+			t.Errorf("expected %d call got %d", 0, hits)
+		}
+	})
+
+	t.Run("Permissions_API", func(t *testing.T) {
+		srv := httptest.NewServer(api.EffectivePermissions(func(merchant string) (*warden.Warden, error) {
+			if merchant != "eliving" {
+				return nil, errors.New("unknown merchant")
+			}
+			return w, nil
+		}))
+		defer srv.Close()
+
+		body, _ := json.Marshal(map[string]interface{}{"merchant": "eliving", "subject": "users:42"})
+		res, err := http.Post(srv.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		defer res.Body.Close()
+
+		var permissions []warden.Permission
+		if err := json.NewDecoder(res.Body).Decode(&permissions); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if res.StatusCode != http.StatusOK || len(permissions) != 3 {
+			t.Errorf("expected 3 permissions got %d (%d)", len(permissions), res.StatusCode)
+		}
+
+		body, _ = json.Marshal(map[string]interface{}{"merchant": "other", "subject": "users:42"})
+		res, err = http.Post(srv.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("expected %d got %d", http.StatusNotFound, res.StatusCode)
+		}
+	})
+}
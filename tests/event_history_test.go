@@ -0,0 +1,143 @@
+package gate_test
+
+import (
+	"context"
+	"github.com/ndv6/gate/internal/models"
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ndv6/gate/mocks"
+	"github.com/ndv6/gate/platform/mongo"
+	"github.com/ory/ladon"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEventHistoryCondition(t *testing.T) {
+	var (
+		now   = time.Now().Unix()
+		day   = int64(24 * 60 * 60)
+		store = &mocks.EventStore{}
+	)
+	for i, action := range []string{"register", "redeem", "redeem", "redeem"} {
+		e := model.NewEvent("users:42", "eliving", action, "", nil)
+		e.EventTime = now - int64(i*10)*day
+		store.Emit(context.TODO(), e, true)
+	}
+	conditions.UseEventStore(store)
+	defer conditions.UseEventStore(nil)
+
+	max := func(n int64) *int64 { return &n }
+	request := &ladon.Request{
+		Resource: "deals:1",
+		Action:   "redeem",
+		Subject:  "users:42",
+		Context:  ladon.Context{"merchant_id": "eliving"},
+	}
+
+	type Pair struct {
+		result    bool
+		condition *conditions.EventHistory
+	}
+
+	var payloads = []Pair{
+		{true, &conditions.EventHistory{Actions: []string{"register"}, Min: 1, Window: 30 * day}},
+		{false, &conditions.EventHistory{Actions: []string{"register"}, Min: 1, Merchant: "other"}},
+		{true, &conditions.EventHistory{Actions: []string{"redeem"}, Min: 2, Window: 25 * day}},
+		{false, &conditions.EventHistory{Actions: []string{"redeem"}, Min: 3, Window: 25 * day}},
+		{true, &conditions.EventHistory{Actions: []string{"redeem"}, Max: max(3)}},
+		{false, &conditions.EventHistory{Actions: []string{"redeem"}, Max: max(2)}},
+		{true, &conditions.EventHistory{Actions: []string{"refund"}, Max: max(0)}},
+	}
+	for i, p := range payloads {
+		if err := p.condition.Validate(); err != nil {
+			t.Errorf("#%d: %+v", i, err)
+		}
+		if r := p.condition.Fulfills(request.Context["merchant_id"], request); r != p.result {
+			t.Errorf("#%d: expected %v got %v", i, p.result, r)
+		}
+	}
+
+	t.Run("EventHistory_StopsAtWindow", func(t *testing.T) {
+		busy := &mocks.EventStore{}
+		for i := 0; i < 500; i++ {
+			e := model.NewEvent("users:7", "eliving", "login", "", nil)
+			e.EventTime = now - int64(i)*day
+			busy.Emit(context.TODO(), e, true)
+		}
+		conditions.UseEventStore(busy)
+		defer conditions.UseEventStore(store)
+
+		c := &conditions.EventHistory{Actions: []string{"login"}, Max: max(500), Window: 10 * day}
+		r := &ladon.Request{Subject: "users:7"}
+		if !c.Fulfills("eliving", r) {
+			t.Errorf("expected %v got %v", true, false)
+		}
+		if busy.Retrieved != 1 {
+			t.Errorf("expected %v got %v", 1, busy.Retrieved)
+		}
+	})
+
+	t.Run("EventHistory_DecisionTime", func(t *testing.T) {
+		// events emitted after the decision time are not counted
+		past := &ladon.Request{Subject: "users:42", Context: ladon.Context{warden.DecisionTimeKey: now - 15*day}}
+
+		var payloads = []Pair{
+			{true, &conditions.EventHistory{Actions: []string{"redeem"}, Max: max(2)}},
+			{true, &conditions.EventHistory{Actions: []string{"redeem"}, Min: 1, Max: max(1), Window: 10 * day}},
+			{false, &conditions.EventHistory{Actions: []string{"register"}, Min: 1, Window: 10 * day}},
+		}
+		for i, p := range payloads {
+			if r := p.condition.Fulfills("eliving", past); r != p.result {
+				t.Errorf("#%d: expected %v got %v", i, p.result, r)
+			}
+		}
+	})
+
+	t.Run("EventHistory_Upserted", func(t *testing.T) {
+		stores := map[string]mongo.EventStore{"mock": &mocks.EventStore{}}
+		// MONGO_URL="mongodb://localhost:27017"
+		if os.Getenv("MONGO_URL") != "" {
+			client := mongo.MongoMustConnect(os.Getenv("MONGO_URL"))
+			db := client.Database("onelabs_event_history_test")
+			defer func() {
+				_ = db.Drop(context.TODO())
+				_ = client.Disconnect(context.TODO())
+			}()
+			stores["mongo"] = mongo.NewEventMongoStore(db.Collection("events"))
+		}
+
+		for name, s := range stores {
+			login := model.NewEvent("users:9", "eliving", "login", "", nil)
+			login.EventTime = now - 90*day
+			redeem := model.NewEvent("users:9", "eliving", "redeem", "", nil)
+			redeem.EventTime = now - 60*day
+			refreshed := model.NewEvent("users:9", "eliving", "login", "", nil)
+			for _, e := range []struct {
+				event    *model.Event
+				multiple bool
+			}{{login, false}, {redeem, true}, {refreshed, false}} {
+				if err := s.Emit(context.TODO(), e.event, e.multiple); err != nil {
+					t.Fatalf("%s: %+v", name, err)
+				}
+			}
+
+			// the refreshed login was stored before the redeem yet happened after it
+			conditions.UseEventStore(s)
+			c := &conditions.EventHistory{Actions: []string{"login", "redeem"}, Min: 1, Window: 10 * day}
+			if !c.Fulfills("eliving", &ladon.Request{Subject: "users:9"}) {
+				t.Errorf("%s: expected %v got %v", name, true, false)
+			}
+		}
+		conditions.UseEventStore(store)
+	})
+
+	t.Run("EventHistory_Validate", func(t *testing.T) {
+		if err := (&conditions.EventHistory{Min: 3, Max: max(1)}).Validate(); err == nil {
+			t.Error("expected validation error for inverted range")
+		}
+		if err := (&conditions.EventHistory{Window: -1}).Validate(); err == nil {
+			t.Error("expected validation error for negative window")
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package gate_test
+
+import (
+	"fmt"
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ory/ladon"
+	"testing"
+)
+
+func TestExpressionCondition(t *testing.T) {
+	request := &ladon.Request{
+		Resource: "room:5",
+		Action:   "create",
+		Subject:  "users:komang",
+		Context: ladon.Context{
+			"amount": 750,
+			"tags":   []string{"vip", "beta"},
+			"order":  map[string]interface{}{"merchant": map[string]interface{}{"id": "m-1"}},
+		},
+	}
+
+	type Pair struct {
+		result     bool
+		expression string
+	}
+
+	var payloads = []Pair{
+		{true, `ctx.amount < 1000 && subject.startsWith("users:")`},
+		{false, `ctx.amount >= 1000 || action == "delete"`},
+		{true, `"vip" in ctx.tags && size(ctx.tags) == 2`},
+		{true, `ctx.order.merchant.id == "m-1"`},
+		{true, `has(ctx.order) && !has(ctx.missing)`},
+		{false, `ctx.missing == 1`},
+		{true, `resource.matches("^room:[0-9]+$")`},
+		{true, `(ctx.amount > 500 ? "high" : "low") == "high"`},
+	}
+	for _, p := range payloads {
+		c := &conditions.Expression{Expression: p.expression}
+		if err := c.Validate(); err != nil {
+			t.Errorf("%s: %+v", p.expression, err)
+			continue
+		}
+		if r := c.Fulfills(nil, request); r != p.result {
+			t.Errorf("%s: expected %v got %v", p.expression, p.result, r)
+		}
+	}
+
+	t.Run("Expression_Validate", func(t *testing.T) {
+		for _, e := range []string{
+			`subject + 1`,
+			`unknown == 1`,
+			`action.startsWith(1)`,
+			`resource.matches("[")`,
+			`ctx.amount < `,
+			`"a" - "b"`,
+		} {
+			c := &conditions.Expression{Expression: e}
+			if err := c.Validate(); err == nil {
+				t.Errorf("%s: expected validation error", e)
+			}
+		}
+	})
+
+	t.Run("Expression_Cost", func(t *testing.T) {
+		c := &conditions.Expression{Expression: `"vip" in ctx.tags`, MaxCost: 2}
+		if c.Fulfills(nil, request) {
+			t.Error("expected evaluation to be aborted by cost limit")
+		}
+	})
+
+	t.Run("Expression_Evicted", func(t *testing.T) {
+		first := &conditions.Expression{Expression: `ctx.amount == 750`}
+		if !first.Fulfills(nil, request) {
+			t.Fatal("expected expression to be fulfilled")
+		}
+		for i := 0; i < 2000; i++ {
+			c := &conditions.Expression{Expression: fmt.Sprintf("ctx.amount == %d", i)}
+			if r := c.Fulfills(nil, request); r != (i == 750) {
+				t.Fatalf("%s: expected %v got %v", c.Expression, i == 750, r)
+			}
+		}
+		if !first.Fulfills(nil, request) {
+			t.Error("expected evicted expression to be compiled again")
+		}
+	})
+}
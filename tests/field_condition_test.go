@@ -0,0 +1,65 @@
+package gate_test
+
+import (
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ory/ladon"
+	"testing"
+)
+
+func TestFieldConditions(t *testing.T) {
+	request := &ladon.Request{
+		Resource: "room:5",
+		Action:   "update",
+		Subject:  "users:42",
+		Context: ladon.Context{
+			"owner_id":   42,
+			"owner_name": "users:42",
+			"tenant":     "eliving",
+		},
+	}
+
+	type Pair struct {
+		result    bool
+		key       string
+		condition ladon.Condition
+	}
+
+	var payloads = []Pair{
+		{true, "owner_name", &conditions.EqualsField{Field: "subject"}},
+		{false, "tenant", &conditions.EqualsField{Field: "subject"}},
+		{false, "missing", &conditions.EqualsField{Field: "subject"}},
+		{true, "owner_id", &conditions.SubjectSegment{Index: 1}},
+		{true, "owner_id", &conditions.SubjectSegment{Index: -1}},
+		{false, "owner_id", &conditions.SubjectSegment{Index: 0}},
+		{true, "", &conditions.SubjectSegment{Index: 1, Field: "context.owner_id"}},
+		{true, "", &conditions.StringPrefix{Prefix: "USERS:", Field: "subject"}},
+		{false, "", &conditions.StringPrefix{Prefix: "users:", Field: "resource"}},
+		{true, "", &conditions.StringList{Options: []string{"update"}, Field: "action"}},
+	}
+	for i, p := range payloads {
+		if v, ok := p.condition.(interface{ Validate() error }); ok {
+			if err := v.Validate(); err != nil {
+				t.Errorf("#%d: %+v", i, err)
+			}
+		}
+		if r := p.condition.Fulfills(request.Context[p.key], request); r != p.result {
+			t.Errorf("#%d %s: expected %v got %v", i, p.condition.GetName(), p.result, r)
+		}
+	}
+
+	t.Run("SubjectSegment_LargeNumber", func(t *testing.T) {
+		r := &ladon.Request{Subject: "users:1234567"}
+		if c := (&conditions.SubjectSegment{Index: 1}); !c.Fulfills(float64(1234567), r) {
+			t.Errorf("expected %v got %v", true, false)
+		}
+	})
+
+	t.Run("Field_Validate", func(t *testing.T) {
+		if err := (&conditions.EqualsField{Field: "context."}).Validate(); err == nil {
+			t.Error("expected validation error for empty context key")
+		}
+		if err := (&conditions.EqualsField{Field: "owner"}).Validate(); err == nil {
+			t.Error("expected validation error for unknown field")
+		}
+	})
+}
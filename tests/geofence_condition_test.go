@@ -0,0 +1,79 @@
+package gate_test
+
+import (
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ory/ladon"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func TestGeofenceCondition(t *testing.T) {
+	// Monas, Jakarta with a 1km radius
+	circle := &conditions.Geofence{Area: conditions.GeoArea{
+		Type:        conditions.GeoPoint,
+		Coordinates: []float64{106.8271, -6.1754},
+		Radius:      1000,
+	}}
+	// square around central Jakarta with a hole in the middle
+	polygon := &conditions.Geofence{Area: conditions.GeoArea{
+		Type: conditions.GeoPolygon,
+		Coordinates: [][][]float64{
+			{{106.80, -6.20}, {106.85, -6.20}, {106.85, -6.15}, {106.80, -6.15}, {106.80, -6.20}},
+			{{106.82, -6.18}, {106.83, -6.18}, {106.83, -6.17}, {106.82, -6.17}},
+		},
+	}}
+
+	type Pair struct {
+		result    bool
+		condition *conditions.Geofence
+		value     interface{}
+	}
+
+	var payloads = []Pair{
+		{true, circle, map[string]interface{}{"latitude": -6.1760, "longitude": 106.8280}},
+		{false, circle, map[string]interface{}{"lat": -6.2000, "lng": 106.8280}},
+		{true, circle, []float64{106.8271, -6.1754}},
+		{true, polygon, map[string]interface{}{"type": "Point", "coordinates": []interface{}{106.81, -6.19}}},
+		{false, polygon, map[string]interface{}{"lat": -6.175, "lon": 106.825}},
+		{false, polygon, map[string]interface{}{"lat": -6.25, "lon": 106.81}},
+		{false, polygon, map[string]interface{}{"lat": -95, "lon": 106.81}},
+		{false, polygon, "somewhere"},
+	}
+	for i, p := range payloads {
+		if err := p.condition.Validate(); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if r := p.condition.Fulfills(p.value, &ladon.Request{}); r != p.result {
+			t.Errorf("#%d %v: expected %v got %v", i, p.value, p.result, r)
+		}
+	}
+
+	t.Run("Geofence_Validate", func(t *testing.T) {
+		invalid := []conditions.GeoArea{
+			{Type: conditions.GeoPoint, Coordinates: []float64{106.8, -6.1}},
+			{Type: conditions.GeoPoint, Coordinates: []float64{200, -6.1}, Radius: 10},
+			{Type: conditions.GeoPolygon, Coordinates: [][][]float64{{{0, 0}, {1, 1}, {0, 0}}}},
+			{Type: "LineString", Coordinates: [][]float64{{0, 0}, {1, 1}}},
+		}
+		for _, a := range invalid {
+			if err := (&conditions.Geofence{Area: a}).Validate(); err == nil {
+				t.Errorf("%+v: expected validation error", a)
+			}
+		}
+	})
+
+	t.Run("Geofence_BSON", func(t *testing.T) {
+		data, err := bson.Marshal(policies.Conditions{"location": polygon})
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		out := policies.Conditions{}
+		if err := bson.Unmarshal(data, &out); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if !out["location"].Fulfills([]float64{106.81, -6.19}, &ladon.Request{}) {
+			t.Error("expected restored condition to be fulfilled")
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package gate_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/ndv6/gate/api"
+	"github.com/ndv6/gate/internal/modules/subjects"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ndv6/gate/mocks"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGrantees(t *testing.T) {
+	mp := memory.NewMemoryManager()
+	for _, p := range []*ladon.DefaultPolicy{
+		{
+			ID:        "staff-delete-rooms",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"room:<.*>"},
+			Actions:   []string{"delete"},
+		},
+		{
+			ID:         "users-delete-room-5",
+			Subjects:   []string{"users:<.*>"},
+			Effect:     ladon.AllowAccess,
+			Resources:  []string{"room:5"},
+			Actions:    []string{"delete"},
+			Conditions: ladon.Conditions{"code": &ladon.StringEqualCondition{Equals: "1234"}},
+		},
+		{
+			ID:        "user-13-no-delete",
+			Subjects:  []string{"users:13"},
+			Effect:    ladon.DenyAccess,
+			Resources: []string{"room:<.*>"},
+			Actions:   []string{"delete"},
+		},
+		{
+			ID:        "contractors-no-room-5",
+			Subjects:  []string{"groups:contractors"},
+			Effect:    ladon.DenyAccess,
+			Resources: []string{"room:5"},
+			Actions:   []string{"delete"},
+		},
+		{
+			ID:        "guests-delete-room-5",
+			Subjects:  []string{"groups:guests"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"room:5"},
+			Actions:   []string{"delete"},
+		},
+		{
+			ID:        "guests-no-delete",
+			Subjects:  []string{"groups:guests"},
+			Effect:    ladon.DenyAccess,
+			Resources: []string{"room:<.*>"},
+			Actions:   []string{"delete"},
+		},
+		{
+			ID:        "admins-get-rooms",
+			Subjects:  []string{"groups:administrators"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"room:<.*>"},
+			Actions:   []string{"get"},
+		},
+	} {
+		if err := mp.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := subjects.NewHierarchy(&mocks.MembershipStore{})
+	for _, m := range [][2]string{
+		{"users:42", "groups:staff"},
+		{"users:13", "groups:staff"},
+		{"users:50", "groups:staff"},
+		{"users:50", "groups:contractors"},
+	} {
+		if err := h.Add(m[0], m[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := warden.NewWarden(mp, ladon.DefaultAuditLogger)
+	w.Subjects = h
+
+	grantees, err := w.Grantees("room:5", "delete")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected = []warden.Grantee{
+		{Subject: "groups:staff", Members: []string{"users:42"}},
+		{Subject: "users:<.*>", Conditional: true, Restrictions: []string{"user-13-no-delete"}},
+	}
+	if len(grantees) != len(expected) {
+		t.Fatalf("expected %d grantees got %+v", len(expected), grantees)
+	}
+	for i, e := range expected {
+		g := grantees[i]
+		if g.Subject != e.Subject || g.Conditional != e.Conditional ||
+			len(g.Restrictions) != len(e.Restrictions) || len(g.Members) != len(e.Members) {
+			t.Errorf("#%d: expected %+v got %+v", i, e, g)
+			continue
+		}
+		for j := range e.Members {
+			if g.Members[j] != e.Members[j] {
+				t.Errorf("#%d: expected members %v got %v", i, e.Members, g.Members)
+			}
+		}
+	}
+
+	t.Run("Grantees_CSV", func(t *testing.T) {
+		srv := httptest.NewServer(api.Grantees(func(merchant string) (*warden.Warden, error) {
+			return w, nil
+		}))
+		defer srv.Close()
+
+		res, err := http.Get(srv.URL + "?merchant=eliving&resource=room:5&action=delete&format=csv")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		defer res.Body.Close()
+
+		records, err := csv.NewReader(res.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if len(records) != 3 {
+			t.Fatalf("expected header and 2 records got %v", records)
+		}
+		if records[1][0] != "groups:staff" || records[1][4] != "users:42" {
+			t.Errorf("unexpected record %v", records[1])
+		}
+	})
+
+	t.Run("Grantees_ServeMux", func(t *testing.T) {
+		srv := httptest.NewServer(api.NewServeMux(api.Handlers{
+			Authenticate: api.BearerToken("secret"),
+			Wardens:      func(merchant string) (*warden.Warden, error) { return w, nil },
+		}))
+		defer srv.Close()
+
+		get := func(token string) *http.Response {
+			r, err := http.NewRequest(http.MethodGet, srv.URL+"/grantees?merchant=eliving&resource=room:5&action=delete", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != "" {
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+			res, err := http.DefaultClient.Do(r)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			return res
+		}
+
+		res := get("")
+		res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected %v got %v", http.StatusUnauthorized, res.StatusCode)
+		}
+
+		res = get("secret")
+		defer res.Body.Close()
+		var got []warden.Grantee
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if len(got) != 2 || len(got[0].Members) != 1 || got[0].Members[0] != "users:42" {
+			t.Errorf("expected members [users:42] of groups:staff got %+v", got)
+		}
+	})
+}
@@ -0,0 +1,241 @@
+package gate_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/ndv6/gate/api"
+	"github.com/ndv6/gate/internal/modules/linter"
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ndv6/gate/platform/mongo"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"go.mongodb.org/mongo-driver/bson"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// jsonManager lists raw policy documents like policies.MongoPolicyManager
+type jsonManager struct {
+	ladon.Manager
+	docs []json.RawMessage
+}
+
+func (m *jsonManager) GetAllJSON(limit, offset int64) ([]json.RawMessage, error) {
+	if offset >= int64(len(m.docs)) {
+		return nil, nil
+	}
+	return m.docs[offset:], nil
+}
+
+func TestPolicyLinter(t *testing.T) {
+	policies := ladon.Policies{
+		&ladon.DefaultPolicy{
+			ID:        "staff-rooms",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"room:<.*>"},
+			Actions:   []string{"get", "update"},
+		},
+		&ladon.DefaultPolicy{
+			ID:        "staff-rooms-copy",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"room:<.*>"},
+			Actions:   []string{"update", "get"},
+		},
+		&ladon.DefaultPolicy{
+			ID:        "staff-room-5",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"room:5"},
+			Actions:   []string{"get"},
+		},
+		&ladon.DefaultPolicy{
+			ID:        "staff-vault",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"vault:1"},
+			Actions:   []string{"open"},
+		},
+		&ladon.DefaultPolicy{
+			ID:        "no-vaults",
+			Subjects:  []string{"<.*>"},
+			Effect:    ladon.DenyAccess,
+			Resources: []string{"vault:<.*>"},
+			Actions:   []string{"open"},
+		},
+		&ladon.DefaultPolicy{
+			ID:        "no-room-7-update",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.DenyAccess,
+			Resources: []string{"room:7"},
+			Actions:   []string{"update"},
+		},
+		&ladon.DefaultPolicy{
+			ID:        "broken",
+			Subjects:  []string{"users:<[0-9>"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"floor:1"},
+			Actions:   []string{"open"},
+		},
+	}
+
+	type Pair struct {
+		policy string
+		kind   string
+	}
+
+	expected := []Pair{
+		{"broken", linter.KindInvalidPattern},
+		{"no-vaults", linter.KindWildcard},
+		{"staff-room-5", linter.KindRedundant},
+		{"staff-rooms", linter.KindDuplicate},
+		{"staff-rooms", linter.KindOverlap},
+		{"staff-rooms-copy", linter.KindOverlap},
+		{"staff-vault", linter.KindShadowed},
+	}
+
+	findings := linter.Lint(policies)
+	got := make(map[Pair]bool)
+	for _, f := range findings {
+		got[Pair{f.Policy, f.Kind}] = true
+	}
+	for _, e := range expected {
+		if !got[e] {
+			t.Errorf("expected %s finding for %s, got %+v", e.kind, e.policy, findings)
+		}
+	}
+	if !linter.HasErrors(findings) {
+		t.Error("expected invalid pattern to be an error")
+	}
+
+	t.Run("Lint_IdenticalPatterns", func(t *testing.T) {
+		pattern := func(id string, effect string) ladon.Policy {
+			return &ladon.DefaultPolicy{
+				ID:        id,
+				Subjects:  []string{"users:<[0-9]+>"},
+				Effect:    effect,
+				Resources: []string{"room:<[0-9]+>"},
+				Actions:   []string{"get"},
+			}
+		}
+		findings := linter.Lint(ladon.Policies{
+			pattern("numbered-rooms", ladon.AllowAccess),
+			pattern("numbered-rooms-copy", ladon.AllowAccess),
+			pattern("no-numbered-rooms", ladon.DenyAccess),
+		})
+
+		got := make(map[Pair]bool)
+		for _, f := range findings {
+			got[Pair{f.Policy, f.Kind}] = true
+		}
+		for _, e := range []Pair{
+			{"numbered-rooms", linter.KindDuplicate},
+			{"numbered-rooms", linter.KindShadowed},
+			{"numbered-rooms-copy", linter.KindShadowed},
+		} {
+			if !got[e] {
+				t.Errorf("expected %s finding for %s, got %+v", e.kind, e.policy, findings)
+			}
+		}
+	})
+
+	t.Run("Lint_UnknownCondition", func(t *testing.T) {
+		data := []byte(`[{"id": "p1", "subjects": ["users:1"], "effect": "allow", "resources": ["room:1"],
+			"actions": ["get"], "conditions": {"x": {"type": "MissingCondition", "options": {}}}}]`)
+		findings, err := linter.LintJSON(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(findings) != 1 || findings[0].Kind != linter.KindUnknownCondition {
+			t.Errorf("expected %s finding got %+v", linter.KindUnknownCondition, findings)
+		}
+	})
+
+	t.Run("Lint_StoredUnknownCondition", func(t *testing.T) {
+		m := &jsonManager{Manager: memory.NewMemoryManager(), docs: []json.RawMessage{
+			json.RawMessage(`{"id": "p1", "subjects": ["users:1"], "effect": "allow", "resources": ["room:1"],
+				"actions": ["get"], "conditions": {"x": {"type": "MissingCondition", "options": {}}}}`),
+		}}
+		findings, err := linter.LintManager(m)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if len(findings) != 1 || findings[0].Kind != linter.KindUnknownCondition {
+			t.Errorf("expected %s finding got %+v", linter.KindUnknownCondition, findings)
+		}
+	})
+
+	t.Run("Lint_API", func(t *testing.T) {
+		mp := memory.NewMemoryManager()
+		for _, p := range policies {
+			if err := mp.Create(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		srv := httptest.NewServer(api.LintPolicies(func(merchant string) (*warden.Warden, error) {
+			return warden.NewWarden(mp, ladon.DefaultAuditLogger), nil
+		}))
+		defer srv.Close()
+
+		res, err := http.Get(srv.URL + "?merchant=eliving")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		defer res.Body.Close()
+
+		var served []linter.Finding
+		if err := json.NewDecoder(res.Body).Decode(&served); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if len(served) != len(findings) {
+			t.Errorf("expected %d findings got %d", len(findings), len(served))
+		}
+
+		body, _ := json.Marshal(policies[:1])
+		res, err = http.Post(srv.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		defer res.Body.Close()
+		served = nil
+		if err := json.NewDecoder(res.Body).Decode(&served); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if res.StatusCode != http.StatusOK || len(served) != 0 {
+			t.Errorf("expected no findings got %+v (%d)", served, res.StatusCode)
+		}
+	})
+}
+
+// MONGO_URL="mongodb://localhost:27017"
+func TestMongoPolicyLinter(t *testing.T) {
+	if os.Getenv("MONGO_URL") == "" {
+		t.Skip("MONGO_URL is not set")
+	}
+	client := mongo.MongoMustConnect(os.Getenv("MONGO_URL"))
+	db := client.Database("onelabs_lint_test")
+	defer func() {
+		_ = db.Drop(context.TODO())
+		_ = client.Disconnect(context.TODO())
+	}()
+
+	_, err := db.Collection("eliving"+policies.PolicyTableSuffix).InsertOne(context.TODO(), bson.M{
+		"_id": "p1", "subjects": bson.A{"users:1"}, "effect": "allow", "resources": bson.A{"room:1"},
+		"actions": bson.A{"get"}, "conditions": bson.M{"x": bson.M{"type": "MissingCondition", "options": bson.M{}}},
+	})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	findings, err := linter.LintManager(policies.NewMongoPolicyManager("eliving", db))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if len(findings) != 1 || findings[0].Kind != linter.KindUnknownCondition {
+		t.Errorf("expected %s finding got %+v", linter.KindUnknownCondition, findings)
+	}
+}
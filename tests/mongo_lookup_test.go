@@ -0,0 +1,218 @@
+package gate_test
+
+import (
+	"context"
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ndv6/gate/internal/modules/simulation"
+	"github.com/ndv6/gate/internal/modules/subjects"
+	"github.com/ndv6/gate/platform/mongo"
+	"github.com/ndv6/gate/warden"
+	"github.com/ory/ladon"
+	"os"
+	"testing"
+)
+
+// MONGO_URL="mongodb://localhost:27017"
+func TestMongoPatternLookup(t *testing.T) {
+	if os.Getenv("MONGO_URL") == "" {
+		t.Skip("MONGO_URL is not set")
+	}
+	client := mongo.MongoMustConnect(os.Getenv("MONGO_URL"))
+	db := client.Database("onelabs_lookup_test")
+	defer func() {
+		_ = db.Drop(context.TODO())
+		_ = client.Disconnect(context.TODO())
+	}()
+
+	mp := policies.NewMongoPolicyManager("eliving", db)
+	for _, p := range []*policies.DefaultPolicy{
+		{
+			ID:        "staff-rooms",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"room:<.*>"},
+			Actions:   []string{"get"},
+		},
+		{
+			ID:        "users-vault",
+			Subjects:  []string{"users:<.*>"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"vault:1"},
+			Actions:   []string{"get"},
+		},
+		{
+			ID:        "no-room-5",
+			Subjects:  []string{"<.*>"},
+			Effect:    ladon.DenyAccess,
+			Resources: []string{"room:5"},
+			Actions:   []string{"get"},
+		},
+		{
+			ID:        "admins-floors",
+			Subjects:  []string{"groups:<admin.*>"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"floor:<.*>"},
+			Actions:   []string{"get"},
+		},
+	} {
+		if err := mp.Create(p); err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+
+	type Pair struct {
+		subject  string
+		expected []string
+	}
+
+	var payloads = []Pair{
+		{"users:42", []string{"users-vault", "no-room-5"}},
+		{"groups:staff", []string{"staff-rooms", "no-room-5"}},
+		{"groups", []string{"staff-rooms", "no-room-5", "admins-floors"}},
+	}
+	for _, p := range payloads {
+		ps, err := mp.FindPoliciesForSubject(p.subject)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if !samePolicies(ps, p.expected) {
+			t.Errorf("%s: expected %v got %v", p.subject, p.expected, policyIDs(ps))
+		}
+	}
+
+	t.Run("MongoLookup_Permissions", func(t *testing.T) {
+		permissions, err := warden.NewWarden(mp, ladon.DefaultAuditLogger).EffectivePermissions("users:42", nil)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if len(permissions) != 1 || permissions[0].Resource != "vault:1" {
+			t.Errorf("expected vault:1 permission got %+v", permissions)
+		}
+
+		permissions, err = warden.NewWarden(mp, ladon.DefaultAuditLogger).EffectivePermissions("groups:staff", nil)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if len(permissions) != 1 || len(permissions[0].Restrictions) != 1 || permissions[0].Restrictions[0] != "no-room-5" {
+			t.Errorf("expected room permission restricted by no-room-5 got %+v", permissions)
+		}
+	})
+
+	t.Run("MongoLookup_Grantees", func(t *testing.T) {
+		ps, err := mp.FindPoliciesForResource("room:5")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if expected := []string{"staff-rooms", "no-room-5"}; !samePolicies(ps, expected) {
+			t.Errorf("expected %v got %v", expected, policyIDs(ps))
+		}
+
+		w := warden.NewWarden(mp, ladon.DefaultAuditLogger)
+		grantees, err := w.Grantees("room:5", "get")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if len(grantees) != 0 {
+			t.Errorf("expected wildcard deny to revoke every grantee got %+v", grantees)
+		}
+
+		grantees, err = w.Grantees("floor:1", "get")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if len(grantees) != 1 || grantees[0].Subject != "groups:<admin.*>" {
+			t.Errorf("expected wildcard grantee got %+v", grantees)
+		}
+	})
+
+	t.Run("MongoLookup_Candidates", func(t *testing.T) {
+		w := warden.NewWarden(mp, ladon.DefaultAuditLogger)
+		// every grant reported by permissions and grantees is allowed when decided
+		for _, subject := range []string{"users:42", "groups:admins"} {
+			permissions, err := w.EffectivePermissions(subject, nil)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			for _, p := range permissions {
+				if p.Resource == "floor:<.*>" {
+					p.Resource = "floor:1"
+				}
+				if err := w.IsAllowed(&ladon.Request{Subject: subject, Resource: p.Resource, Action: p.Action}); err != nil {
+					t.Errorf("%s %s %s: expected %v got %v", subject, p.Action, p.Resource, nil, err)
+				}
+			}
+		}
+
+		grantees, err := w.Grantees("vault:1", "get")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if len(grantees) != 1 || grantees[0].Subject != "users:<.*>" {
+			t.Fatalf("expected wildcard grantee got %+v", grantees)
+		}
+		if err := w.IsAllowed(&ladon.Request{Subject: "users:7", Resource: "vault:1", Action: "get"}); err != nil {
+			t.Errorf("expected %v got %v", nil, err)
+		}
+		if err := w.IsAllowed(&ladon.Request{Subject: "groups:staff", Resource: "room:5", Action: "get"}); err == nil {
+			t.Error("expected wildcard deny to apply")
+		}
+	})
+
+	t.Run("MongoLookup_Resolvers", func(t *testing.T) {
+		if err := subjects.NewHierarchy(subjects.NewMongoMembershipStore("eliving", db)).Add("users:43", "groups:staff"); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		w := warden.NewMongoWarden("eliving", db, "", nil)
+		if err := w.IsAllowed(&ladon.Request{Subject: "users:43", Resource: "room:6", Action: "get"}); err != nil {
+			t.Errorf("expected %v got %v", nil, err)
+		}
+
+		grantees, err := w.Grantees("room:6", "get")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if len(grantees) != 1 || len(grantees[0].Members) != 1 || grantees[0].Members[0] != "users:43" {
+			t.Errorf("expected staff members got %+v", grantees)
+		}
+	})
+
+	t.Run("MongoLookup_Simulation", func(t *testing.T) {
+		requests := []ladon.Request{
+			{Subject: "users:42", Resource: "vault:1", Action: "get"},
+			{Subject: "groups:admins", Resource: "floor:1", Action: "get"},
+			{Subject: "groups:staff", Resource: "room:5", Action: "get"},
+			{Subject: "groups:staff", Resource: "room:6", Action: "get"},
+		}
+		report, err := simulation.Simulate(warden.NewWarden(mp, ladon.DefaultAuditLogger), &simulation.ChangeSet{}, requests)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if report.Evaluated != len(requests) || report.Flipped != 0 {
+			t.Errorf("expected %v got %+v", 0, report)
+		}
+	})
+}
+
+func policyIDs(ps ladon.Policies) []string {
+	ids := make([]string, 0, len(ps))
+	for _, p := range ps {
+		ids = append(ids, p.GetID())
+	}
+	return ids
+}
+
+func samePolicies(ps ladon.Policies, ids []string) bool {
+	if len(ps) != len(ids) {
+		return false
+	}
+	found := make(map[string]bool)
+	for _, p := range ps {
+		found[p.GetID()] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return false
+		}
+	}
+	return true
+}
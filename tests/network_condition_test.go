@@ -0,0 +1,69 @@
+package gate_test
+
+import (
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ory/ladon"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func TestNetworkCondition(t *testing.T) {
+	c := &conditions.Network{
+		Allow:          []string{"10.0.0.0/8", "2001:db8::/32", "203.0.113.7"},
+		Exclude:        []string{"10.13.0.0/16", "2001:db8:dead::/48"},
+		TrustedProxies: 1,
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	type Pair struct {
+		result bool
+		value  interface{}
+	}
+
+	var payloads = []Pair{
+		{true, "10.1.2.3, 172.16.0.1"},
+		{false, "10.13.2.3, 172.16.0.1"},
+		{true, "198.51.100.1, 203.0.113.7:4431, 172.16.0.1"},
+		{false, "8.8.8.8, 172.16.0.1"},
+		{true, "[2001:db8:1::5]:443, 172.16.0.1"},
+		{false, "2001:db8:dead::5, 172.16.0.1"},
+		{true, []string{"10.1.2.3", "172.16.0.1"}},
+		{false, "not-an-ip, 172.16.0.1"},
+		{false, "10.1.2.3"},
+		{false, 42},
+	}
+	for i, p := range payloads {
+		if r := c.Fulfills(p.value, &ladon.Request{}); r != p.result {
+			t.Errorf("#%d %v: expected %v got %v", i, p.value, p.result, r)
+		}
+	}
+
+	t.Run("Network_Validate", func(t *testing.T) {
+		if err := (&conditions.Network{Allow: []string{"10.0.0.0/33"}}).Validate(); err == nil {
+			t.Error("expected validation error for invalid range")
+		}
+		if err := (&conditions.Network{Allow: []string{"10.0.0.0/8"}, Exclude: []string{"foo"}}).Validate(); err == nil {
+			t.Error("expected validation error for invalid exclusion")
+		}
+		if err := (&conditions.Network{}).Validate(); err == nil {
+			t.Error("expected validation error for empty ranges")
+		}
+	})
+
+	t.Run("Network_BSON", func(t *testing.T) {
+		data, err := bson.Marshal(policies.Conditions{"ip": c})
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		out := policies.Conditions{}
+		if err := bson.Unmarshal(data, &out); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if !out["ip"].Fulfills("10.1.2.3, 172.16.0.1", &ladon.Request{}) {
+			t.Error("expected restored condition to be fulfilled")
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package gate_test
+
+import (
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+	"time"
+)
+
+func TestPathSelectorCondition(t *testing.T) {
+	request := &ladon.Request{
+		Resource: "deals:1",
+		Action:   "redeem",
+		Subject:  "users:42",
+		Context: ladon.Context{
+			"order": map[string]interface{}{
+				"merchant": map[string]interface{}{"id": "eliving-01"},
+				"items":    []interface{}{map[string]interface{}{"sku-id": "A1"}, map[string]interface{}{"sku-id": "B2"}},
+			},
+		},
+	}
+
+	type Pair struct {
+		result bool
+		path   string
+		prefix string
+	}
+
+	var payloads = []Pair{
+		{true, "merchant.id", "eliving"},
+		{true, "$.merchant.id", "ELIVING"},
+		{true, `items[0]["sku-id"]`, "A"},
+		{true, `items[-1]['sku-id']`, "B"},
+		{false, `items[2]["sku-id"]`, "B"},
+		{false, "merchant.name", "eliving"},
+	}
+	for _, p := range payloads {
+		c := &conditions.PathSelector{Path: p.path, Condition: &conditions.StringPrefix{Prefix: p.prefix}}
+		if err := c.Validate(); err != nil {
+			t.Errorf("%s: %+v", p.path, err)
+		}
+		if r := c.Fulfills(request.Context["order"], request); r != p.result {
+			t.Errorf("%s: expected %v got %v", p.path, p.result, r)
+		}
+	}
+
+	t.Run("PathSelector_Validate", func(t *testing.T) {
+		for _, p := range []string{"merchant..id", "items[x]", "items[0", "items]"} {
+			c := &conditions.PathSelector{Path: p, Condition: &conditions.StringPrefix{}}
+			if err := c.Validate(); err == nil {
+				t.Errorf("%s: expected validation error", p)
+			}
+		}
+	})
+
+	t.Run("PathSelector_BSON", func(t *testing.T) {
+		in := policies.Conditions{
+			"order": &conditions.PathSelector{
+				Path:      "merchant.id",
+				Condition: &conditions.StringPrefix{Prefix: "eliving", CaseSensitive: true},
+			},
+		}
+		data, err := bson.Marshal(in)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+
+		out := policies.Conditions{}
+		if err := bson.Unmarshal(data, &out); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		c, ok := out["order"].(*conditions.PathSelector)
+		if !ok {
+			t.Fatalf("expected PathSelector got %T", out["order"])
+		}
+		if !c.Fulfills(request.Context["order"], request) {
+			t.Error("expected restored condition to be fulfilled")
+		}
+	})
+
+	t.Run("PathSelector_Delegates", func(t *testing.T) {
+		mp := memory.NewMemoryManager()
+		if err := mp.Create(&ladon.DefaultPolicy{
+			ID:        "payout-update",
+			Subjects:  []string{"users:<.*>"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"payout"},
+			Actions:   []string{"update"},
+			Conditions: ladon.Conditions{
+				"session": &conditions.PathSelector{Path: "id", Condition: &conditions.AuthFreshness{MaxAge: 300}},
+				"kyc":     &conditions.PathSelector{Path: "id", Condition: &conditions.Webhook{URL: "http://127.0.0.1:1"}},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		w := warden.NewWarden(mp, ladon.DefaultAuditLogger)
+		w.DryRun = true
+		r := &ladon.Request{Subject: "users:1", Resource: "payout", Action: "update", Context: ladon.Context{
+			"session":   map[string]interface{}{"id": "s-1"},
+			"kyc":       map[string]interface{}{"id": "k-1"},
+			"auth_time": time.Now().Unix() - 3600,
+		}}
+		d, err := w.Decide(r)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if d.Allowed || !d.StepUpRequired {
+			t.Errorf("expected step-up of the nested condition got %+v", d)
+		}
+
+		r.Context["auth_time"] = time.Now().Unix()
+		d, err = w.Decide(r)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		// the nested webhook is assumed fulfilled rather than called in a dry run
+		if !d.Allowed || len(d.Conditions) != 2 {
+			t.Fatalf("expected allowed decision explaining both conditions got %+v", d)
+		}
+		for _, c := range d.Conditions {
+			if c.Details["path"] != "id" && c.Details["assumed"] != true {
+				t.Errorf("expected selector details got %+v", c)
+			}
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package gate_test
+
+import (
+	"bytes"
+	"github.com/ndv6/gate/policytest"
+	"github.com/ndv6/gate/warden"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"strings"
+	"testing"
+)
+
+type staticSubjects map[string][]string
+
+func (s staticSubjects) Expand(subject string) ([]string, error) {
+	return append([]string{subject}, s[subject]...), nil
+}
+
+const testPolicies = `
+- id: admins-rooms
+  subjects: ["groups:administrators"]
+  effect: allow
+  resources: ["room:<.*>"]
+  actions: ["create", "update", "delete"]
+  conditions:
+    va:
+      type: StringPrefixCondition
+      options:
+        prefix: PRE-
+- id: no-room-1
+  subjects: ["<.*>"]
+  effect: deny
+  resources: ["room:1"]
+  actions: ["<.*>"]
+`
+
+const testCases = `
+cases:
+  - name: administrators create rooms
+    request:
+      subject: groups:administrators
+      resource: room:5
+      action: create
+      context:
+        va: PRE-5
+    expect: allow
+  - name: nobody touches room 1
+    request:
+      subject: groups:administrators
+      resource: room:1
+      action: create
+      context:
+        va: PRE-5
+    expect: deny
+  - name: virtual account is required
+    request:
+      subject: groups:administrators
+      resource: room:5
+      action: create
+    expect: deny
+`
+
+func TestPolicyTestRunner(t *testing.T) {
+	m, err := policytest.ParsePolicies([]byte(testPolicies))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	suite, err := policytest.ParseSuite([]byte(testCases))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	policytest.AssertSuite(t, m, suite)
+
+	t.Run("Runner_Failures", func(t *testing.T) {
+		failing, err := policytest.ParseSuite([]byte(`{"cases": [{"name": "room 1", "expect": "allow",
+			"request": {"subject": "groups:administrators", "resource": "room:1", "action": "update", "context": {"va": "PRE-1"}}}]}`))
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+
+		report := policytest.Run(m, failing)
+		if report.Failed != 1 || len(report.Failures()) != 1 {
+			t.Fatalf("expected 1 failure got %+v", report)
+		}
+
+		var out bytes.Buffer
+		if err := report.Write(&out, false); err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range []string{"FAIL room 1: expected allow got deny", "policy no-room-1 (deny) applied"} {
+			if !strings.Contains(out.String(), expected) {
+				t.Errorf("expected report to contain %q got\n%s", expected, out.String())
+			}
+		}
+	})
+
+	t.Run("Runner_InvalidExpectation", func(t *testing.T) {
+		if _, err := policytest.ParseSuite([]byte(`cases: [{expect: maybe}]`)); err == nil {
+			t.Error("expected unknown outcome to be rejected")
+		}
+	})
+
+	t.Run("Runner_Options", func(t *testing.T) {
+		member, err := policytest.ParseSuite([]byte(`{"cases": [{"name": "member creates rooms", "expect": "allow",
+			"request": {"subject": "users:alice", "resource": "room:5", "action": "create", "context": {"va": "PRE-5"}}}]}`))
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+
+		if report := policytest.Run(m, member); report.Failed != 1 {
+			t.Errorf("expected %v got %v", 1, report.Failed)
+		}
+		opts := policytest.Options{Subjects: staticSubjects{"users:alice": {"groups:administrators"}}}
+		policytest.AssertSuiteWith(t, m, member, opts)
+	})
+
+	t.Run("Runner_WardenUntouched", func(t *testing.T) {
+		w := warden.NewWarden(m, ladon.DefaultAuditLogger)
+		if report := policytest.RunWarden(w, suite); report.Failed != 0 {
+			t.Errorf("expected %v got %v", 0, report.Failed)
+		}
+		if w.Trace || w.DryRun {
+			t.Error("expected tracing and dry run to be enabled on a copy of the warden")
+		}
+	})
+
+	t.Run("Runner_SideEffects", func(t *testing.T) {
+		counting := &countingCondition{}
+		mp := memory.NewMemoryManager()
+		if err := mp.Create(&ladon.DefaultPolicy{
+			ID:         "counted-rooms",
+			Subjects:   []string{"users:alice"},
+			Effect:     ladon.AllowAccess,
+			Resources:  []string{"room:<.*>"},
+			Actions:    []string{"get"},
+			Conditions: ladon.Conditions{"count": counting},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		counted, err := policytest.ParseSuite([]byte(`{"cases": [{"name": "alice gets rooms", "expect": "allow",
+			"request": {"subject": "users:alice", "resource": "room:5", "action": "get"}}]}`))
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+
+		if report := policytest.RunWarden(warden.NewWarden(mp, nil), counted); report.Failed != 0 {
+			t.Errorf("expected %v got %v", 0, report.Failed)
+		}
+		if counting.calls != 0 {
+			t.Errorf("expected %v got %v", 0, counting.calls)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package gate_test
+
+import (
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/platform/redis"
+	"github.com/ory/ladon"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// REDIS_URL="redis://localhost:6379/0"
+func TestRateLimitCondition(t *testing.T) {
+	if os.Getenv("REDIS_URL") == "" {
+		t.Skip("REDIS_URL is not set")
+	}
+	client := redis.RedisMustConnect(os.Getenv("REDIS_URL"))
+	defer client.Close()
+
+	conditions.UseRedis(client)
+	defer conditions.UseRedis(nil)
+
+	keys := []string{conditions.RateLimitKeyPrefix + "eliving:otp-send:devices:1", conditions.RateLimitKeyPrefix + "other:otp-send:devices:1"}
+	client.Del(keys...)
+	defer client.Del(keys...)
+
+	c := &conditions.RateLimit{Limit: 5, Window: 60, KeyField: "context.device_id", Name: "otp-send"}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	request := &ladon.Request{
+		Resource: "otp",
+		Action:   "send",
+		Subject:  "users:42",
+		Context:  ladon.Context{"device_id": "devices:1"},
+	}
+
+	var (
+		wg      sync.WaitGroup
+		allowed int64
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if c.Fulfills("eliving", request) {
+				atomic.AddInt64(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 5 {
+		t.Errorf("expected %d got %d", 5, allowed)
+	}
+
+	t.Run("RateLimit_Merchant", func(t *testing.T) {
+		if !c.Fulfills("other", request) {
+			t.Error("expected counters of another merchant to be separate")
+		}
+		if c.Fulfills(nil, request) {
+			t.Error("expected request without merchant to be refused")
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package gate_test
+
+import (
+	"github.com/ndv6/gate/internal/modules/rbac"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ndv6/gate/mocks"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+// failingManager fails creating policies granting delete a given number of times
+type failingManager struct {
+	ladon.Manager
+	failures int
+}
+
+func (m *failingManager) Create(p ladon.Policy) error {
+	if m.failures > 0 && len(p.GetActions()) > 0 && p.GetActions()[0] == "delete" {
+		m.failures--
+		return errors.New("unavailable")
+	}
+	return m.Manager.Create(p)
+}
+
+func TestRBACManager(t *testing.T) {
+	mp := memory.NewMemoryManager()
+	m := rbac.NewManager(&mocks.RBACStore{}, mp)
+	w := warden.NewWarden(mp, ladon.DefaultAuditLogger)
+
+	editor := &rbac.Role{
+		Name: "room-editor",
+		Permissions: []rbac.Permission{
+			{Resources: []string{"room:<.*>"}, Actions: []string{"create", "update"}},
+		},
+	}
+	if err := m.CreateRole(editor); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CreateRole(editor); errors.Cause(err) != rbac.ErrRoleExists {
+		t.Errorf("expected %v got %v", rbac.ErrRoleExists, err)
+	}
+
+	global := &rbac.RoleBinding{ID: "editors", Role: "room-editor", Subjects: []string{"users:1"}}
+	scoped := &rbac.RoleBinding{ID: "tower-editors", Role: "room-editor", Subjects: []string{"users:2"}, Scope: "room:tower"}
+	for _, b := range []*rbac.RoleBinding{global, scoped} {
+		if err := m.Bind(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type Pair struct {
+		result  bool
+		request ladon.Request
+	}
+
+	check := func(t *testing.T, payloads []Pair) {
+		for i, p := range payloads {
+			d, err := w.Decide(&p.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Allowed != p.result {
+				t.Errorf("#%d: expected %v got %v", i, p.result, d.Allowed)
+			}
+		}
+	}
+
+	t.Run("RBAC_Bind", func(t *testing.T) {
+		check(t, []Pair{
+			{true, ladon.Request{Subject: "users:1", Resource: "room:5", Action: "create"}},
+			{false, ladon.Request{Subject: "users:1", Resource: "room:5", Action: "delete"}},
+			{true, ladon.Request{Subject: "users:2", Resource: "room:tower:5", Action: "update"}},
+			{true, ladon.Request{Subject: "users:2", Resource: "room:tower", Action: "update"}},
+			{false, ladon.Request{Subject: "users:2", Resource: "room:towers", Action: "update"}},
+			{false, ladon.Request{Subject: "users:2", Resource: "room:tower-5", Action: "update"}},
+			{false, ladon.Request{Subject: "users:2", Resource: "room:5", Action: "update"}},
+			{false, ladon.Request{Subject: "users:3", Resource: "room:5", Action: "create"}},
+		})
+	})
+
+	t.Run("RBAC_InvalidScope", func(t *testing.T) {
+		for _, scope := range []string{"room:", "room:<.*>", "room::1"} {
+			b := &rbac.RoleBinding{ID: "invalid", Role: "room-editor", Subjects: []string{"users:9"}, Scope: scope}
+			if err := m.Bind(b); errors.Cause(err) != rbac.ErrRBACInvalidParameter {
+				t.Errorf("%s: expected %v got %v", scope, rbac.ErrRBACInvalidParameter, err)
+			}
+		}
+	})
+
+	t.Run("RBAC_Rebind", func(t *testing.T) {
+		rebound := &rbac.RoleBinding{ID: "tower-editors", Role: "room-editor", Subjects: []string{"users:4"}, Scope: "room:tower"}
+		if err := m.Bind(rebound); err != nil {
+			t.Fatal(err)
+		}
+		check(t, []Pair{
+			{true, ladon.Request{Subject: "users:4", Resource: "room:tower:5", Action: "update"}},
+			{false, ladon.Request{Subject: "users:2", Resource: "room:tower:5", Action: "update"}},
+		})
+	})
+
+	t.Run("RBAC_UpdateRole", func(t *testing.T) {
+		editor.Permissions = []rbac.Permission{
+			{Resources: []string{"room:<.*>"}, Actions: []string{"update"}},
+			{Resources: []string{"floor:<.*>"}, Actions: []string{"delete"}},
+		}
+		if err := m.UpdateRole(editor); err != nil {
+			t.Fatal(err)
+		}
+		check(t, []Pair{
+			{false, ladon.Request{Subject: "users:1", Resource: "room:5", Action: "create"}},
+			{true, ladon.Request{Subject: "users:1", Resource: "room:5", Action: "update"}},
+			{true, ladon.Request{Subject: "users:1", Resource: "floor:1", Action: "delete"}},
+			{false, ladon.Request{Subject: "users:4", Resource: "floor:1", Action: "delete"}},
+		})
+	})
+
+	t.Run("RBAC_Recover", func(t *testing.T) {
+		flaky := &failingManager{Manager: mp, failures: 1}
+		m := rbac.NewManager(m.Store, flaky)
+
+		b := &rbac.RoleBinding{ID: "floor-editors", Role: "room-editor", Subjects: []string{"users:5"}}
+		if err := m.Bind(b); err == nil {
+			t.Fatal("expected bind to fail")
+		}
+		if err := m.Bind(b); err != nil {
+			t.Fatal(err)
+		}
+		check(t, []Pair{
+			{true, ladon.Request{Subject: "users:5", Resource: "floor:1", Action: "delete"}},
+		})
+		if err := m.Unbind("floor-editors"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("RBAC_Unbind", func(t *testing.T) {
+		if err := m.DeleteRole("room-editor"); errors.Cause(err) != rbac.ErrRoleInUse {
+			t.Errorf("expected %v got %v", rbac.ErrRoleInUse, err)
+		}
+		for _, id := range []string{"editors", "tower-editors"} {
+			if err := m.Unbind(id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		all, err := mp.GetAll(100, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 0 {
+			t.Errorf("expected managed policies to be deleted, got %d", len(all))
+		}
+		if err := m.DeleteRole("room-editor"); err != nil {
+			t.Error(err)
+		}
+	})
+}
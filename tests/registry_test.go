@@ -0,0 +1,67 @@
+package gate_test
+
+import (
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ndv6/gate/registry"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+type tenantCondition struct {
+	Tenant string `json:"tenant" bson:"tenant"`
+}
+
+func (c *tenantCondition) Fulfills(value interface{}, _ *ladon.Request) bool {
+	return value == c.Tenant
+}
+
+func (c *tenantCondition) GetName() string {
+	return "TenantCondition"
+}
+
+func TestConditionRegistry(t *testing.T) {
+	if err := registry.Register(func() ladon.Condition { return new(tenantCondition) }); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	t.Run("Registry_Collision", func(t *testing.T) {
+		err := registry.Register(func() ladon.Condition { return new(tenantCondition) })
+		if errors.Cause(err) != registry.ErrConditionExists {
+			t.Errorf("expected %v got %v", registry.ErrConditionExists, err)
+		}
+		err = registry.Register(func() ladon.Condition { return new(conditions.StringPrefix) })
+		if errors.Cause(err) != registry.ErrConditionExists {
+			t.Errorf("expected %v got %v", registry.ErrConditionExists, err)
+		}
+		err = registry.Register(func() ladon.Condition { return new(ladon.StringEqualCondition) })
+		if errors.Cause(err) != registry.ErrConditionExists {
+			t.Errorf("expected %v got %v", registry.ErrConditionExists, err)
+		}
+	})
+
+	t.Run("Registry_Lookup", func(t *testing.T) {
+		if !registry.Exists("TenantCondition") || !registry.Exists("StringPrefixCondition") {
+			t.Errorf("expected conditions to be registered, got %v", registry.List())
+		}
+		if _, err := registry.Lookup("UnknownCondition"); errors.Cause(err) != registry.ErrConditionNotFound {
+			t.Errorf("expected %v got %v", registry.ErrConditionNotFound, err)
+		}
+	})
+
+	t.Run("Registry_BSON", func(t *testing.T) {
+		data, err := bson.Marshal(policies.Conditions{"tenant": &tenantCondition{Tenant: "eliving"}})
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		out := policies.Conditions{}
+		if err := bson.Unmarshal(data, &out); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if !out["tenant"].Fulfills("eliving", &ladon.Request{}) {
+			t.Error("expected restored condition to be fulfilled")
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package gate_test
+
+import (
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/internal/modules/simulation"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"strings"
+	"testing"
+	"time"
+)
+
+type countingCondition struct {
+	calls int
+}
+
+func (c *countingCondition) GetName() string {
+	return "CountingCondition"
+}
+
+func (c *countingCondition) Fulfills(value interface{}, r *ladon.Request) bool {
+	c.calls++
+	return true
+}
+
+func (c *countingCondition) SideEffects() bool {
+	return true
+}
+
+func TestReplay(t *testing.T) {
+	candidate := memory.NewMemoryManager()
+	for _, p := range []*ladon.DefaultPolicy{
+		{
+			ID:        "staff-rooms",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"room:<.*>"},
+			Actions:   []string{"get", "update"},
+		},
+		{
+			ID:        "no-room-1",
+			Subjects:  []string{"<.*>"},
+			Effect:    ladon.DenyAccess,
+			Resources: []string{"room:1"},
+			Actions:   []string{"<.*>"},
+		},
+	} {
+		if err := candidate.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records := []simulation.Record{
+		{Request: ladon.Request{Subject: "groups:staff", Resource: "room:5", Action: "get"}, Outcome: "allow", Deciders: []string{"staff-rooms"}},
+		{Request: ladon.Request{Subject: "groups:staff", Resource: "room:5", Action: "update"}, Outcome: "deny"},
+		{Request: ladon.Request{Subject: "groups:staff", Resource: "room:6", Action: "update"}, Outcome: "deny"},
+		{Request: ladon.Request{Subject: "groups:staff", Resource: "room:1", Action: "get"}, Outcome: "allow", Deciders: []string{"staff-room-1"}},
+		{Request: ladon.Request{Subject: "groups:guests", Resource: "room:5", Action: "get"}, Outcome: "deny"},
+	}
+
+	report, err := simulation.Replay(new(warden.Warden), candidate, simulation.Records(records))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Replayed != 5 || report.Flipped != 3 || len(report.Flips) != 3 {
+		t.Fatalf("expected 3 of 5 flips got %+v", report)
+	}
+
+	type Pair struct {
+		group    []simulation.FlipGroup
+		expected simulation.FlipGroup
+	}
+
+	var payloads = []Pair{
+		{report.BySubject, simulation.FlipGroup{Key: "groups:staff", Flipped: 3, Granted: 2, Revoked: 1}},
+		{report.ByResource, simulation.FlipGroup{Key: "room:1", Flipped: 1, Revoked: 1}},
+		{report.ByPolicy, simulation.FlipGroup{Key: "staff-rooms", Flipped: 2, Granted: 2}},
+		{report.ByPolicy, simulation.FlipGroup{Key: "no-room-1", Flipped: 1, Revoked: 1}},
+		{report.ByPolicy, simulation.FlipGroup{Key: "staff-room-1", Flipped: 1, Revoked: 1}},
+	}
+	for i, p := range payloads {
+		found := false
+		for _, g := range p.group {
+			if g.Key == p.expected.Key {
+				found = true
+				if g != p.expected {
+					t.Errorf("#%d: expected %+v got %+v", i, p.expected, g)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("#%d: expected group %s in %+v", i, p.expected.Key, p.group)
+		}
+	}
+
+	t.Run("Replay_JSONLines", func(t *testing.T) {
+		log := `{"request": {"subject": "groups:staff", "resource": "room:5", "action": "update"}, "outcome": "deny"}
+
+{"request": {"subject": "groups:staff", "resource": "room:5", "action": "get"}, "outcome": "allow"}
+`
+		report, err := simulation.Replay(new(warden.Warden), candidate, simulation.NewJSONRecordReader(strings.NewReader(log)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Replayed != 2 || report.Flipped != 1 {
+			t.Errorf("expected 1 of 2 flips got %+v", report)
+		}
+
+		_, err = simulation.Replay(new(warden.Warden), candidate, simulation.NewJSONRecordReader(strings.NewReader("{")))
+		if err == nil {
+			t.Error("expected invalid record to fail the replay")
+		}
+	})
+
+	t.Run("Replay_SideEffects", func(t *testing.T) {
+		c := &countingCondition{}
+		limited := memory.NewMemoryManager()
+		if err := limited.Create(&ladon.DefaultPolicy{
+			ID:         "staff-rooms-limited",
+			Subjects:   []string{"groups:staff"},
+			Effect:     ladon.AllowAccess,
+			Resources:  []string{"room:<.*>"},
+			Actions:    []string{"get"},
+			Conditions: ladon.Conditions{"limit": c},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := simulation.Replay(new(warden.Warden), limited, simulation.Records(records))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// allowed requests stay allowed as the side-effecting condition is assumed fulfilled
+		if report.Replayed != 5 || report.Flipped != 0 {
+			t.Errorf("expected 0 of 5 flips got %+v", report)
+		}
+		if c.calls != 0 {
+			t.Errorf("expected %v got %v", 0, c.calls)
+		}
+	})
+
+	t.Run("Replay_DecisionTime", func(t *testing.T) {
+		fresh := memory.NewMemoryManager()
+		if err := fresh.Create(&ladon.DefaultPolicy{
+			ID:         "fresh-payouts",
+			Subjects:   []string{"users:<.*>"},
+			Effect:     ladon.AllowAccess,
+			Resources:  []string{"payout:account"},
+			Actions:    []string{"update"},
+			Conditions: ladon.Conditions{"auth": &conditions.AuthFreshness{MaxAge: 300}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		authenticated := time.Now().Add(-time.Hour).Unix()
+		request := ladon.Request{Subject: "users:1", Resource: "payout:account", Action: "update",
+			Context: ladon.Context{"auth_time": authenticated}}
+		records := []simulation.Record{
+			{Request: request, Outcome: "allow", Deciders: []string{"fresh-payouts"}, Time: authenticated + 60},
+			{Request: request, Outcome: "step_up", Time: authenticated + 600},
+		}
+
+		report, err := simulation.Replay(new(warden.Warden), fresh, simulation.Records(records))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Replayed != 2 || report.Flipped != 0 {
+			t.Errorf("expected 0 of 2 flips got %+v", report)
+		}
+	})
+}
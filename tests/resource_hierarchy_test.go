@@ -0,0 +1,115 @@
+package gate_test
+
+import (
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestResourceHierarchy(t *testing.T) {
+	t.Run("Resource_Ancestors", func(t *testing.T) {
+		expected := []string{"room:1:door:3", "room:1:door", "room:1", "room"}
+		ancestors := policies.Ancestors("room:1:door:3", ":")
+		if len(ancestors) != len(expected) {
+			t.Fatalf("expected %v got %v", expected, ancestors)
+		}
+		for i := range expected {
+			if ancestors[i] != expected[i] {
+				t.Errorf("expected %v got %v", expected, ancestors)
+			}
+		}
+		if ancestors := policies.Ancestors("room:1", ""); len(ancestors) != 1 {
+			t.Errorf("expected only the resource got %v", ancestors)
+		}
+	})
+
+	mp := memory.NewMemoryManager()
+	for _, p := range []*ladon.DefaultPolicy{
+		{
+			ID:        "staff-open-room-1",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"room:1"},
+			Actions:   []string{"open"},
+		},
+		{
+			ID:        "staff-no-safe",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.DenyAccess,
+			Resources: []string{"room:1:safe"},
+			Actions:   []string{"open"},
+		},
+	} {
+		if err := mp.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type Pair struct {
+		result  bool
+		request ladon.Request
+	}
+
+	var payloads = []Pair{
+		{true, ladon.Request{Subject: "groups:staff", Resource: "room:1", Action: "open"}},
+		{true, ladon.Request{Subject: "groups:staff", Resource: "room:1:door:3", Action: "open"}},
+		{false, ladon.Request{Subject: "groups:staff", Resource: "room:1:safe", Action: "open"}},
+		{false, ladon.Request{Subject: "groups:staff", Resource: "room:1:safe:drawer", Action: "open"}},
+		{false, ladon.Request{Subject: "groups:staff", Resource: "room:10", Action: "open"}},
+		{false, ladon.Request{Subject: "groups:staff", Resource: "room:2:door:1", Action: "open"}},
+	}
+
+	t.Run("Resource_Inheritance", func(t *testing.T) {
+		w := warden.NewWarden(mp, ladon.DefaultAuditLogger)
+		w.ResourceSeparator = ":"
+		for i, p := range payloads {
+			d, err := w.Decide(&p.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Allowed != p.result {
+				t.Errorf("#%d: expected %v got %v", i, p.result, d.Allowed)
+			}
+		}
+	})
+
+	t.Run("Resource_OptIn", func(t *testing.T) {
+		w := warden.NewWarden(mp, ladon.DefaultAuditLogger)
+		d, err := w.Decide(&payloads[1].request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Allowed {
+			t.Error("expected parent policy not to apply without a separator")
+		}
+	})
+
+	t.Run("Resource_ManagerSeparator", func(t *testing.T) {
+		w := warden.NewWarden(&hierarchicalManager{Manager: mp, separator: ":"}, ladon.DefaultAuditLogger)
+		d, err := w.Decide(&payloads[1].request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d.Allowed {
+			t.Error("expected separator of the manager to apply")
+		}
+
+		w.ResourceSeparator = "/"
+		if _, err := w.Decide(&payloads[1].request); errors.Cause(err) != warden.ErrSeparatorMismatch {
+			t.Errorf("expected %v got %v", warden.ErrSeparatorMismatch, err)
+		}
+	})
+}
+
+// hierarchicalManager looks ancestors up the way policies.MongoPolicyManager does
+type hierarchicalManager struct {
+	ladon.Manager
+	separator string
+}
+
+func (m *hierarchicalManager) ResourceSeparator() string {
+	return m.separator
+}
@@ -0,0 +1,137 @@
+package gate_test
+
+import (
+	"context"
+	"github.com/ndv6/gate/internal/models"
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ndv6/gate/mocks"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"testing"
+	"time"
+)
+
+func TestRiskScoreCondition(t *testing.T) {
+	var (
+		now   = time.Now().Unix()
+		hour  = int64(60 * 60)
+		store = &mocks.EventStore{}
+	)
+	for i, action := range []string{"login_failed", "login_failed", "device_changed", "login_failed"} {
+		e := model.NewEvent("users:42", "eliving", action, "", nil)
+		e.EventTime = now - int64(i)*hour
+		store.Emit(context.TODO(), e, true)
+	}
+	conditions.UseEventStore(store)
+	defer conditions.UseEventStore(nil)
+
+	weights := map[string]float64{"login_failed": 10, "device_changed": 25}
+	request := &ladon.Request{
+		Resource: "payout:1",
+		Action:   "create",
+		Subject:  "users:42",
+		Context:  ladon.Context{"merchant_id": "eliving"},
+	}
+
+	type Pair struct {
+		result    bool
+		condition *conditions.RiskScore
+	}
+
+	var payloads = []Pair{
+		{true, &conditions.RiskScore{Weights: weights, Threshold: 55}},
+		{false, &conditions.RiskScore{Weights: weights, Threshold: 54}},
+		{true, &conditions.RiskScore{Weights: weights, Window: 2 * hour, Threshold: 45}},
+		{false, &conditions.RiskScore{Weights: weights, Window: 2 * hour, Threshold: 44}},
+		{true, &conditions.RiskScore{NewMerchantWeight: 50, Threshold: 10}},
+		{false, &conditions.RiskScore{NewMerchantWeight: 50, Merchant: "other", Threshold: 10}},
+	}
+	for i, p := range payloads {
+		if err := p.condition.Validate(); err != nil {
+			t.Errorf("#%d: %+v", i, err)
+		}
+		if r := p.condition.Fulfills(request.Context["merchant_id"], request); r != p.result {
+			t.Errorf("#%d: expected %v got %v", i, p.result, r)
+		}
+	}
+
+	t.Run("RiskScore_Validate", func(t *testing.T) {
+		if err := (&conditions.RiskScore{Threshold: 10}).Validate(); err == nil {
+			t.Error("expected validation error without weights")
+		}
+		if err := (&conditions.RiskScore{Weights: weights, Window: -1}).Validate(); err == nil {
+			t.Error("expected validation error for negative window")
+		}
+	})
+
+	t.Run("RiskScore_StopsAboveThreshold", func(t *testing.T) {
+		busy := &mocks.EventStore{}
+		for i := 0; i < 500; i++ {
+			e := model.NewEvent("users:7", "eliving", "login_failed", "", nil)
+			e.EventTime = now - int64(i)
+			busy.Emit(context.TODO(), e, true)
+		}
+		conditions.UseEventStore(busy)
+		defer conditions.UseEventStore(store)
+
+		c := &conditions.RiskScore{Weights: weights, Threshold: 30}
+		if c.Fulfills("eliving", &ladon.Request{Subject: "users:7"}) {
+			t.Errorf("expected %v got %v", false, true)
+		}
+		if busy.Retrieved != 1 {
+			t.Errorf("expected %v got %v", 1, busy.Retrieved)
+		}
+	})
+
+	t.Run("RiskScore_Upserted", func(t *testing.T) {
+		upserted := &mocks.EventStore{}
+		device := model.NewEvent("users:9", "eliving", "device_changed", "", nil)
+		device.EventTime = now - 48*hour
+		failed := model.NewEvent("users:9", "eliving", "login_failed", "", nil)
+		failed.EventTime = now - 24*hour
+		upserted.Emit(context.TODO(), device, false)
+		upserted.Emit(context.TODO(), failed, true)
+		upserted.Emit(context.TODO(), model.NewEvent("users:9", "eliving", "device_changed", "", nil), false)
+		conditions.UseEventStore(upserted)
+		defer conditions.UseEventStore(store)
+
+		// the device changed again just now though its event was stored first
+		c := &conditions.RiskScore{Weights: weights, Window: 2 * hour, Threshold: 20}
+		if c.Fulfills("eliving", &ladon.Request{Subject: "users:9"}) {
+			t.Errorf("expected %v got %v", false, true)
+		}
+	})
+
+	t.Run("RiskScore_Decision", func(t *testing.T) {
+		mp := memory.NewMemoryManager()
+		if err := mp.Create(&ladon.DefaultPolicy{
+			ID:         "payout-create",
+			Subjects:   []string{"users:<.*>"},
+			Effect:     ladon.AllowAccess,
+			Resources:  []string{"payout:<.*>"},
+			Actions:    []string{"create"},
+			Conditions: ladon.Conditions{"merchant_id": &conditions.RiskScore{Weights: weights, Threshold: 50}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := warden.NewWarden(mp, ladon.DefaultAuditLogger).Decide(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Allowed {
+			t.Error("expected request to be denied")
+		}
+		if len(d.Conditions) != 1 {
+			t.Fatalf("expected 1 condition result got %d", len(d.Conditions))
+		}
+		c := d.Conditions[0]
+		if c.Policy != "payout-create" || c.Type != "RiskScoreCondition" || c.Passed {
+			t.Errorf("unexpected condition result %+v", c)
+		}
+		if score := c.Details["score"]; score != float64(55) {
+			t.Errorf("expected score %v got %v", 55, score)
+		}
+	})
+}
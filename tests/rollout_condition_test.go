@@ -0,0 +1,93 @@
+package gate_test
+
+import (
+	"fmt"
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ory/ladon"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func TestRolloutCondition(t *testing.T) {
+	c := &conditions.Rollout{Percentage: 25, Salt: "new-checkout"}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	var in int
+	for i := 0; i < 10000; i++ {
+		r := &ladon.Request{Subject: fmt.Sprintf("users:%d", i)}
+		first := c.Fulfills(nil, r)
+		if first != c.Fulfills(nil, r) {
+			t.Fatalf("users:%d expected a stable outcome", i)
+		}
+		if first {
+			in++
+		}
+	}
+	if in < 2300 || in > 2700 {
+		t.Errorf("expected roughly 2500 subjects in rollout got %d", in)
+	}
+
+	t.Run("Rollout_Overrides", func(t *testing.T) {
+		none := &conditions.Rollout{Percentage: 0, Allow: []string{"users:1"}}
+		if !none.Fulfills(nil, &ladon.Request{Subject: "users:1"}) {
+			t.Error("expected allowlisted subject to be included")
+		}
+		all := &conditions.Rollout{Percentage: 100, Allow: []string{"users:2"}, Deny: []string{"users:2"}}
+		if all.Fulfills(nil, &ladon.Request{Subject: "users:2"}) {
+			t.Error("expected denylisted subject to be excluded")
+		}
+		if !all.Fulfills(nil, &ladon.Request{Subject: "users:3"}) {
+			t.Error("expected every other subject to be included")
+		}
+	})
+
+	t.Run("Rollout_FractionalPercentage", func(t *testing.T) {
+		fractional := &conditions.Rollout{Percentage: 0.57, Salt: "new-checkout"}
+		if err := fractional.Validate(); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		// 0.57 percent of buckets are 0 to 56 though 0.57 * 100 is slightly below 57 as a float
+		for i := 0; i < 100000; i++ {
+			subject := fmt.Sprintf("users:%d", i)
+			if conditions.RolloutBucket("new-checkout", subject) != 56 {
+				continue
+			}
+			if !fractional.Fulfills(nil, &ladon.Request{Subject: subject}) {
+				t.Errorf("expected %s in bucket 56 to be included", subject)
+			}
+			break
+		}
+
+		if err := (&conditions.Rollout{Percentage: 0.125}).Validate(); err == nil {
+			t.Error("expected validation error for more than two decimals")
+		}
+	})
+
+	t.Run("Rollout_KeyField", func(t *testing.T) {
+		byMerchant := &conditions.Rollout{Percentage: 100, KeyField: "context.merchant_id"}
+		if byMerchant.Fulfills(nil, &ladon.Request{Subject: "users:1"}) {
+			t.Error("expected missing key to be excluded")
+		}
+		if !byMerchant.Fulfills(nil, &ladon.Request{Context: ladon.Context{"merchant_id": "eliving"}}) {
+			t.Error("expected merchant to be included")
+		}
+	})
+
+	t.Run("Rollout_BSON", func(t *testing.T) {
+		data, err := bson.Marshal(policies.Conditions{"rollout": c})
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		out := policies.Conditions{}
+		if err := bson.Unmarshal(data, &out); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		r := &ladon.Request{Subject: "users:7"}
+		if out["rollout"].Fulfills(nil, r) != c.Fulfills(nil, r) {
+			t.Error("expected restored condition to keep the same bucketing")
+		}
+	})
+}
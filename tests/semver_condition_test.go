@@ -0,0 +1,78 @@
+package gate_test
+
+import (
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ory/ladon"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func TestSemVerCondition(t *testing.T) {
+	type Pair struct {
+		result     bool
+		constraint string
+		version    string
+	}
+
+	var payloads = []Pair{
+		{true, ">=3.4.0 <4", "3.4.0"},
+		{true, ">=3.4.0 <4", "v3.9.12"},
+		{false, ">=3.4.0 <4", "4.0.0"},
+		{false, ">=3.4.0 <4", "4.0.0-beta.1"},
+		{false, ">=3.4.0 <4", "3.3.9"},
+		{true, ">= 3.4.0, < 4", "3.5.0"},
+		{true, "^3.4", "3.99.0"},
+		{false, "^3.4", "3.3.0"},
+		{true, "^0.2.3", "0.2.9"},
+		{false, "^0.2.3", "0.3.0"},
+		{false, "^0.0.3", "0.0.4"},
+		{true, "~3.4.1", "3.4.7"},
+		{false, "~3.4.1", "3.5.0"},
+		{true, "3.x", "3.2.1"},
+		{false, "3.x", "4.0.0"},
+		{true, "~2.9.1 || >=4.1", "4.2.0"},
+		{true, "~2.9.1 || >=4.1", "2.9.3"},
+		{false, "~2.9.1 || >=4.1", "3.0.0"},
+		{true, ">3.4", "3.5.0"},
+		{false, ">3.4", "3.4.9"},
+		{true, "<=3.4", "3.4.9"},
+		{false, "!=3.5.2", "3.5.2"},
+		{true, ">=4.0.0-beta.1", "4.0.0-beta.2"},
+		{false, ">=4.0.0-beta.2", "4.0.0-beta.1"},
+		{true, "<1.0.0 || *", "1.2.3"},
+		{false, "*", "not-a-version"},
+	}
+	for _, p := range payloads {
+		c := &conditions.SemVer{Constraint: p.constraint}
+		if err := c.Validate(); err != nil {
+			t.Errorf("%s: %+v", p.constraint, err)
+			continue
+		}
+		if r := c.Fulfills(p.version, &ladon.Request{}); r != p.result {
+			t.Errorf("%s against %s: expected %v got %v", p.constraint, p.version, p.result, r)
+		}
+	}
+
+	t.Run("SemVer_Validate", func(t *testing.T) {
+		for _, c := range []string{"", ">=", "3.4.0 ||", "~>3.4", "3.04.0", "!=3.4", "1.2.3.4"} {
+			if err := (&conditions.SemVer{Constraint: c}).Validate(); err == nil {
+				t.Errorf("%q: expected validation error", c)
+			}
+		}
+	})
+
+	t.Run("SemVer_BSON", func(t *testing.T) {
+		data, err := bson.Marshal(policies.Conditions{"app_version": &conditions.SemVer{Constraint: ">=3.4.0 <4"}})
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		out := policies.Conditions{}
+		if err := bson.Unmarshal(data, &out); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if !out["app_version"].Fulfills("3.4.2", &ladon.Request{}) {
+			t.Error("expected restored condition to be fulfilled")
+		}
+	})
+}
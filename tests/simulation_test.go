@@ -0,0 +1,155 @@
+package gate_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ndv6/gate/api"
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/internal/modules/simulation"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type recentRequests []ladon.Request
+
+func (l recentRequests) RecentRequests(limit int) ([]ladon.Request, error) {
+	if limit < len(l) {
+		return l[:limit], nil
+	}
+	return l, nil
+}
+
+func TestSimulation(t *testing.T) {
+	mp := memory.NewMemoryManager()
+	for _, p := range []*ladon.DefaultPolicy{
+		{
+			ID:        "staff-rooms",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"room:<.*>"},
+			Actions:   []string{"get"},
+		},
+		{
+			ID:        "no-room-1",
+			Subjects:  []string{"<.*>"},
+			Effect:    ladon.DenyAccess,
+			Resources: []string{"room:1"},
+			Actions:   []string{"<.*>"},
+		},
+	} {
+		if err := mp.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := warden.NewWarden(mp, ladon.DefaultAuditLogger)
+
+	changes := &simulation.ChangeSet{
+		Create: []*ladon.DefaultPolicy{{
+			ID:        "guests-rooms",
+			Subjects:  []string{"groups:guests"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"room:<.*>"},
+			Actions:   []string{"get"},
+		}},
+		Update: []*ladon.DefaultPolicy{{
+			ID:        "staff-rooms",
+			Subjects:  []string{"groups:staff"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"room:<.*>"},
+			Actions:   []string{"get", "update"},
+		}},
+		Delete: []string{"no-room-1"},
+	}
+	requests := recentRequests{
+		{Subject: "groups:staff", Resource: "room:5", Action: "get"},
+		{Subject: "groups:staff", Resource: "room:5", Action: "update"},
+		{Subject: "groups:staff", Resource: "room:1", Action: "get"},
+		{Subject: "groups:guests", Resource: "room:5", Action: "get"},
+		{Subject: "groups:guests", Resource: "room:5", Action: "delete"},
+	}
+
+	report, err := simulation.Simulate(w, changes, requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Evaluated != 5 || report.Flipped != 3 {
+		t.Fatalf("expected 3 of 5 flips got %+v", report)
+	}
+	for i, f := range report.Flips {
+		if f.Before != warden.OutcomeDeny || f.After != warden.OutcomeAllow {
+			t.Errorf("#%d: expected deny to allow got %s to %s", i, f.Before, f.After)
+		}
+	}
+	if _, err := mp.Get("no-room-1"); err != nil {
+		t.Errorf("expected current policies to be untouched: %v", err)
+	}
+
+	t.Run("Simulation_InvalidChange", func(t *testing.T) {
+		_, err := simulation.Simulate(w, &simulation.ChangeSet{Delete: []string{"missing"}}, requests)
+		if errors.Cause(err) != simulation.ErrChangeInvalid {
+			t.Errorf("expected %v got %v", simulation.ErrChangeInvalid, err)
+		}
+	})
+
+	t.Run("Simulation_DecisionTime", func(t *testing.T) {
+		fresh := memory.NewMemoryManager()
+		if err := fresh.Create(&ladon.DefaultPolicy{
+			ID:         "fresh-payouts",
+			Subjects:   []string{"users:<.*>"},
+			Effect:     ladon.AllowAccess,
+			Resources:  []string{"payout:account"},
+			Actions:    []string{"update"},
+			Conditions: ladon.Conditions{"auth": &conditions.AuthFreshness{MaxAge: 300}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		authenticated := time.Now().Add(-time.Hour)
+		past := warden.NewWarden(fresh, nil)
+		past.Now = func() time.Time { return authenticated.Add(time.Minute) }
+
+		// dropping the condition changes nothing at the time of the warden clock
+		report, err := simulation.Simulate(past, &simulation.ChangeSet{Update: []*ladon.DefaultPolicy{{
+			ID:        "fresh-payouts",
+			Subjects:  []string{"users:<.*>"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"payout:account"},
+			Actions:   []string{"update"},
+		}}}, []ladon.Request{{Subject: "users:1", Resource: "payout:account", Action: "update",
+			Context: ladon.Context{"auth_time": authenticated.Unix()}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Flipped != 0 {
+			t.Errorf("expected %v got %+v", 0, report)
+		}
+	})
+
+	t.Run("Simulation_API", func(t *testing.T) {
+		srv := httptest.NewServer(api.SimulatePolicyChanges(
+			func(merchant string) (*warden.Warden, error) { return w, nil },
+			func(merchant string) (simulation.RequestLog, error) { return requests, nil },
+		))
+		defer srv.Close()
+
+		body, _ := json.Marshal(map[string]interface{}{"merchant": "eliving", "changes": changes, "recent": 2})
+		res, err := http.Post(srv.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		defer res.Body.Close()
+
+		var served simulation.Report
+		if err := json.NewDecoder(res.Body).Decode(&served); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if served.Evaluated != 2 || served.Flipped != 1 {
+			t.Errorf("expected 1 of 2 flips got %+v", served)
+		}
+	})
+}
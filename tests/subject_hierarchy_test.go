@@ -0,0 +1,137 @@
+package gate_test
+
+import (
+	"github.com/ndv6/gate/internal/modules/subjects"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ndv6/gate/mocks"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"github.com/pkg/errors"
+	"strconv"
+	"testing"
+)
+
+func TestSubjectHierarchy(t *testing.T) {
+	h := subjects.NewHierarchy(&mocks.MembershipStore{})
+	for _, m := range [][2]string{
+		{"users:42", "groups:administrators"},
+		{"users:43", "groups:operators"},
+		{"groups:administrators", "groups:operators"},
+		{"groups:operators", "roles:viewer"},
+	} {
+		if err := h.Add(m[0], m[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Hierarchy_Expand", func(t *testing.T) {
+		expanded, err := h.Expand("users:42")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"users:42", "groups:administrators", "groups:operators", "roles:viewer"}
+		if len(expanded) != len(expected) {
+			t.Fatalf("expected %v got %v", expected, expanded)
+		}
+		for i := range expected {
+			if expanded[i] != expected[i] {
+				t.Errorf("expected %v got %v", expected, expanded)
+			}
+		}
+	})
+
+	t.Run("Hierarchy_Cycle", func(t *testing.T) {
+		for _, m := range [][2]string{
+			{"roles:viewer", "groups:administrators"},
+			{"groups:operators", "groups:operators"},
+		} {
+			if err := h.Add(m[0], m[1]); errors.Cause(err) != subjects.ErrMembershipCycle {
+				t.Errorf("expected %v got %v", subjects.ErrMembershipCycle, err)
+			}
+		}
+
+		// cycles stored by other means must not loop forever
+		store := &mocks.MembershipStore{}
+		store.Insert("groups:a", "groups:b")
+		store.Insert("groups:b", "groups:a")
+		expanded, err := subjects.NewHierarchy(store).Expand("groups:a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(expanded) != 2 {
+			t.Errorf("expected 2 subjects got %v", expanded)
+		}
+	})
+
+	t.Run("Hierarchy_TooDeep", func(t *testing.T) {
+		// groups:0 belongs to groups:1 which belongs to groups:2 and so on
+		store := &mocks.MembershipStore{}
+		for i := 0; i < 5; i++ {
+			store.Insert("groups:"+strconv.Itoa(i), "groups:"+strconv.Itoa(i+1))
+		}
+		deep := &subjects.Hierarchy{Store: store, MaxDepth: 4}
+		if _, err := deep.Expand("groups:0"); errors.Cause(err) != subjects.ErrMembershipTooDeep {
+			t.Errorf("expected %v got %v", subjects.ErrMembershipTooDeep, err)
+		}
+		if _, err := deep.Members("groups:5"); errors.Cause(err) != subjects.ErrMembershipTooDeep {
+			t.Errorf("expected %v got %v", subjects.ErrMembershipTooDeep, err)
+		}
+		if expanded, err := deep.Expand("groups:1"); err != nil || len(expanded) != 5 {
+			t.Errorf("expected 5 subjects got %v (%v)", expanded, err)
+		}
+
+		// the cycle check is not bounded by the maximum depth
+		if err := deep.Add("groups:5", "groups:0"); errors.Cause(err) != subjects.ErrMembershipCycle {
+			t.Errorf("expected %v got %v", subjects.ErrMembershipCycle, err)
+		}
+	})
+
+	t.Run("Hierarchy_Decide", func(t *testing.T) {
+		mp := memory.NewMemoryManager()
+		policies := []*ladon.DefaultPolicy{
+			{
+				ID:        "admins-create-room",
+				Subjects:  []string{"groups:administrators"},
+				Effect:    ladon.AllowAccess,
+				Resources: []string{"room:<.*>"},
+				Actions:   []string{"create"},
+			},
+			{
+				ID:        "viewers-get-room",
+				Subjects:  []string{"roles:viewer"},
+				Effect:    ladon.AllowAccess,
+				Resources: []string{"room:<.*>"},
+				Actions:   []string{"get"},
+			},
+		}
+		for _, p := range policies {
+			if err := mp.Create(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		w := warden.NewWarden(mp, ladon.DefaultAuditLogger)
+		w.Subjects = h
+
+		type Pair struct {
+			result  bool
+			request ladon.Request
+		}
+
+		var payloads = []Pair{
+			{true, ladon.Request{Subject: "users:42", Resource: "room:5", Action: "create"}},
+			{true, ladon.Request{Subject: "users:42", Resource: "room:5", Action: "get"}},
+			{false, ladon.Request{Subject: "users:43", Resource: "room:5", Action: "create"}},
+			{true, ladon.Request{Subject: "users:43", Resource: "room:5", Action: "get"}},
+			{false, ladon.Request{Subject: "users:44", Resource: "room:5", Action: "get"}},
+		}
+		for i, p := range payloads {
+			d, err := w.Decide(&p.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Allowed != p.result {
+				t.Errorf("#%d: expected %v got %v", i, p.result, d.Allowed)
+			}
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package gate_test
+
+import (
+	"encoding/json"
+	"github.com/ndv6/gate/internal/modules/conditions"
+	"github.com/ndv6/gate/internal/modules/simulation"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookCondition(t *testing.T) {
+	secret := []byte("s3cr3t")
+	conditions.UseWebhookKeys(map[string][]byte{"kyc": secret})
+	defer conditions.UseWebhookKeys(map[string][]byte{})
+
+	var hits int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "/kyc":
+			ts := r.Header.Get(conditions.WebhookTimestampHeader)
+			if r.Header.Get(conditions.WebhookSignatureHeader) != "sha256="+conditions.SignWebhook(secret, ts, body) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+
+		var in struct {
+			Request    ladon.Request          `json:"request"`
+			Value      interface{}            `json:"value"`
+			Parameters map[string]interface{} `json:"parameters"`
+		}
+		if err := json.Unmarshal(body, &in); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		allowed := in.Value == "eliving" && in.Parameters["level"] == "full"
+		json.NewEncoder(w).Encode(map[string]bool{"allow": allowed})
+	}))
+	defer srv.Close()
+
+	request := &ladon.Request{Resource: "payout", Action: "create", Subject: "merchants:eliving"}
+	params := map[string]interface{}{"level": "full"}
+
+	type Pair struct {
+		result    bool
+		value     interface{}
+		condition *conditions.Webhook
+	}
+
+	var payloads = []Pair{
+		{true, "eliving", &conditions.Webhook{URL: srv.URL + "/kyc", Parameters: params, KeyID: "kyc"}},
+		{false, "other", &conditions.Webhook{URL: srv.URL + "/kyc", Parameters: params, KeyID: "kyc"}},
+		{false, "eliving", &conditions.Webhook{URL: srv.URL + "/kyc", Parameters: params}},
+		{false, "eliving", &conditions.Webhook{URL: srv.URL + "/slow", Parameters: params, Timeout: 50}},
+		{true, "eliving", &conditions.Webhook{URL: srv.URL + "/slow", Parameters: params, Timeout: 50, FailOpen: true}},
+		{false, "eliving", &conditions.Webhook{URL: srv.URL + "/broken", Parameters: params}},
+		{true, "eliving", &conditions.Webhook{URL: srv.URL + "/broken", Parameters: params, FailOpen: true}},
+	}
+	for i, p := range payloads {
+		if err := p.condition.Validate(); err != nil {
+			t.Errorf("#%d: %+v", i, err)
+		}
+		if r := p.condition.Fulfills(p.value, request); r != p.result {
+			t.Errorf("#%d: expected %v got %v", i, p.result, r)
+		}
+	}
+
+	t.Run("Webhook_Cache", func(t *testing.T) {
+		c := &conditions.Webhook{URL: srv.URL + "/cached", Parameters: params, CacheTTL: 60}
+		before := atomic.LoadInt64(&hits)
+		for i := 0; i < 5; i++ {
+			if !c.Fulfills("eliving", request) {
+				t.Error("expected condition to be fulfilled")
+			}
+		}
+		if n := atomic.LoadInt64(&hits) - before; n != 1 {
+			t.Errorf("expected %d call got %d", 1, n)
+		}
+	})
+
+	t.Run("Webhook_EvaluatedLast", func(t *testing.T) {
+		mp := memory.NewMemoryManager()
+		if err := mp.Create(&ladon.DefaultPolicy{
+			ID:        "payout-create",
+			Subjects:  []string{"merchants:<.*>"},
+			Effect:    ladon.AllowAccess,
+			Resources: []string{"payout"},
+			Actions:   []string{"create"},
+			Conditions: ladon.Conditions{
+				"a_kyc":  &conditions.Webhook{URL: srv.URL + "/uncached", Parameters: params},
+				"z_code": &ladon.StringEqualCondition{Equals: "1234"},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		before := atomic.LoadInt64(&hits)
+		r := &ladon.Request{Resource: "payout", Action: "create", Subject: "merchants:eliving",
+			Context: ladon.Context{"a_kyc": "eliving", "z_code": "0000"}}
+		if err := warden.NewWarden(mp, ladon.DefaultAuditLogger).IsAllowed(r); err == nil {
+			t.Error("expected request to be denied")
+		}
+		if n := atomic.LoadInt64(&hits) - before; n != 0 {
+			t.Errorf("expected %d call got %d", 0, n)
+		}
+
+		r.Context["z_code"] = "1234"
+		if err := warden.NewWarden(mp, ladon.DefaultAuditLogger).IsAllowed(r); err != nil {
+			t.Errorf("expected request to be allowed: %v", err)
+		}
+		if n := atomic.LoadInt64(&hits) - before; n != 1 {
+			t.Errorf("expected %d call got %d", 1, n)
+		}
+	})
+
+	t.Run("Webhook_DryRun", func(t *testing.T) {
+		mp := memory.NewMemoryManager()
+		if err := mp.Create(&ladon.DefaultPolicy{
+			ID:         "payout-create",
+			Subjects:   []string{"merchants:<.*>"},
+			Effect:     ladon.AllowAccess,
+			Resources:  []string{"payout"},
+			Actions:    []string{"create"},
+			Conditions: ladon.Conditions{"kyc": &conditions.Webhook{URL: srv.URL + "/uncached", Parameters: params}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		before := atomic.LoadInt64(&hits)
+		w := warden.NewWarden(mp, ladon.DefaultAuditLogger)
+		w.DryRun = true
+		d, err := w.Decide(&ladon.Request{Resource: "payout", Action: "create", Subject: "merchants:eliving",
+			Context: ladon.Context{"kyc": "eliving"}})
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if !d.Allowed || len(d.Conditions) != 1 || d.Conditions[0].Details["assumed"] != true {
+			t.Errorf("expected webhook to be assumed fulfilled got %+v", d)
+		}
+
+		requests := []ladon.Request{{Resource: "payout", Action: "create", Subject: "merchants:eliving",
+			Context: ladon.Context{"kyc": "eliving"}}}
+		report, err := simulation.Simulate(warden.NewWarden(mp, ladon.DefaultAuditLogger), &simulation.ChangeSet{Delete: []string{"payout-create"}}, requests)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if report.Flipped != 1 || report.Flips[0].Before != warden.OutcomeAllow {
+			t.Errorf("expected allowed payout to flip got %+v", report)
+		}
+		if n := atomic.LoadInt64(&hits) - before; n != 0 {
+			t.Errorf("expected %d call got %d", 0, n)
+		}
+	})
+
+	t.Run("Webhook_Validate", func(t *testing.T) {
+		invalid := []*conditions.Webhook{
+			{URL: "ftp://example.com"},
+			{URL: srv.URL, Timeout: -1},
+			{URL: srv.URL, KeyID: "unknown"},
+		}
+		for i, c := range invalid {
+			if err := c.Validate(); err == nil {
+				t.Errorf("#%d: expected validation error", i)
+			}
+		}
+	})
+}
@@ -0,0 +1,103 @@
+// Package warden exposes the policy evaluator to applications embedding GateOne, every type is the one
+// used internally so wardens built here can be handed to the `api` handlers and the `policytest` runner
+package warden
+
+import (
+	"io"
+
+	"github.com/ndv6/gate/internal/modules/actions"
+	"github.com/ndv6/gate/internal/modules/policies"
+	"github.com/ndv6/gate/internal/modules/subjects"
+	"github.com/ndv6/gate/internal/modules/warden"
+	"github.com/ory/ladon"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	// registers conditions shipped with gate so stored policies can use them
+	_ "github.com/ndv6/gate/internal/modules/conditions"
+)
+
+// Warden evaluates access requests the same way ladon does while reporting how the decision was made
+type Warden = warden.Warden
+
+// Decision outcome of an access request
+type Decision = warden.Decision
+
+// ConditionResult details of a condition reported during evaluation
+type ConditionResult = warden.ConditionResult
+
+// PolicyTrace tells how a policy was handled while deciding
+type PolicyTrace = warden.PolicyTrace
+
+// Permission resource and action a subject is allowed on
+type Permission = warden.Permission
+
+// Grantee subject or subject pattern allowed to perform an action on a resource
+type Grantee = warden.Grantee
+
+// Matcher checks whether a request attribute matches one of policy attributes
+type Matcher = warden.Matcher
+
+// SubjectResolver expands a subject into itself and every group or role it transitively belongs to
+type SubjectResolver = warden.SubjectResolver
+
+// MemberResolver lists users and groups belonging to a group
+type MemberResolver = warden.MemberResolver
+
+// ActionResolver expands an action into itself and every action group transitively including it
+type ActionResolver = warden.ActionResolver
+
+// DecisionLogger is implemented by audit loggers recording whole decisions
+type DecisionLogger = warden.DecisionLogger
+
+// StepUpCondition is implemented by conditions whose failure can be resolved by authenticating again
+type StepUpCondition = warden.StepUpCondition
+
+// SideEffectCondition is implemented by conditions whose evaluation changes state or reaches external services
+type SideEffectCondition = warden.SideEffectCondition
+
+// Explainer is implemented by conditions reporting details of their evaluation
+type Explainer = warden.Explainer
+
+const (
+	// OutcomeAllow request is allowed
+	OutcomeAllow = warden.OutcomeAllow
+
+	// OutcomeDeny request is refused
+	OutcomeDeny = warden.OutcomeDeny
+
+	// OutcomeStepUp request is refused until the caller authenticates again
+	OutcomeStepUp = warden.OutcomeStepUp
+
+	// DecisionTimeKey context key holding the unix time a request is decided at, set by the warden
+	DecisionTimeKey = warden.DecisionTimeKey
+)
+
+var (
+	// ErrStepUpRequired is returned when the request would be allowed once the caller authenticates again
+	ErrStepUpRequired = warden.ErrStepUpRequired
+
+	// ErrSeparatorMismatch is returned when the warden and its manager split resources differently
+	ErrSeparatorMismatch = warden.ErrSeparatorMismatch
+)
+
+// NewWarden evaluates requests against policies of given manager, a nil logger audits nothing
+func NewWarden(m ladon.Manager, l ladon.AuditLogger) *Warden {
+	return warden.NewWarden(m, l)
+}
+
+// NewMongoWarden evaluates requests against policies of the merchant stored in mongo, subjects are
+// expanded with group memberships and actions with action groups of the merchant stored along with them
+// a non empty separator enables hierarchical resources, see Warden.ResourceSeparator
+func NewMongoWarden(merchant string, db *mongo.Database, separator string, l ladon.AuditLogger) *Warden {
+	m := policies.NewMongoPolicyManager(merchant, db)
+	m.UseResourceSeparator(separator)
+	w := warden.NewWarden(m, l)
+	w.Subjects = subjects.NewHierarchy(subjects.NewMongoMembershipStore(merchant, db))
+	w.Actions = actions.NewResolver(actions.NewMongoGroupStore(merchant, db))
+	return w
+}
+
+// WriteGranteesCSV exports grantees as CSV, list columns are joined with `;`
+func WriteGranteesCSV(out io.Writer, grantees []Grantee) error {
+	return warden.WriteGranteesCSV(out, grantees)
+}